@@ -0,0 +1,275 @@
+package wincred
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limits of a credential, as defined by CRED_MAX_ATTRIBUTES,
+// CRED_MAX_STRING_LENGTH, CRED_MAX_VALUE_SIZE and
+// CRED_MAX_CREDENTIAL_BLOB_SIZE.
+const (
+	MaxAttributes           = 64
+	MaxAttributeKeywordSize = 256
+	MaxAttributeValueSize   = 256
+	MaxCredentialBlobSize   = 2560
+)
+
+var (
+	// ErrInvalidKeyword is returned for empty or too long attribute keywords.
+	ErrInvalidKeyword = errors.New("invalid attribute keyword")
+
+	// ErrTooManyAttributes is returned when adding an attribute to a credential
+	// that already has the maximum number of attributes.
+	ErrTooManyAttributes = errors.New("too many attributes")
+
+	// ErrAttributeTooLarge is returned for attribute values exceeding
+	// MaxAttributeValueSize bytes.
+	ErrAttributeTooLarge = errors.New("attribute value too large")
+)
+
+// FILETIME counts 100-nanosecond intervals since 1601-01-01 UTC.
+const (
+	// filetimeEpochSeconds is the number of seconds between the FILETIME epoch
+	// and the Unix epoch.
+	filetimeEpochSeconds = 11644473600
+	// filetimeSecond is the number of FILETIME intervals per second.
+	filetimeSecond = 10000000
+)
+
+// attribute returns a pointer to the attribute with the given keyword or nil
+// if the credential has no such attribute.
+func (t *Credential) attribute(keyword string) *CredentialAttribute {
+	for i := range t.Attributes {
+		if t.Attributes[i].Keyword == keyword {
+			return &t.Attributes[i]
+		}
+	}
+	return nil
+}
+
+// setAttribute sets the value of the attribute with the given keyword.
+// The attribute is appended if the credential does not have it yet.
+func (t *Credential) setAttribute(keyword string, value []byte) {
+	if attr := t.attribute(keyword); attr != nil {
+		attr.Value = value
+		return
+	}
+	t.Attributes = append(t.Attributes, CredentialAttribute{Keyword: keyword, Value: value})
+}
+
+// removeAttribute removes the attribute with the given keyword, if present.
+func (t *Credential) removeAttribute(keyword string) {
+	for i := range t.Attributes {
+		if t.Attributes[i].Keyword == keyword {
+			t.Attributes = append(t.Attributes[:i], t.Attributes[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetAttribute returns the value of the attribute with the given keyword and
+// whether the credential has such attribute.
+func (t *Credential) GetAttribute(keyword string) ([]byte, bool) {
+	if attr := t.attribute(keyword); attr != nil {
+		return attr.Value, true
+	}
+	return nil, false
+}
+
+// SetAttribute sets the value of the attribute with the given keyword,
+// replacing the value of an existing attribute with the same keyword.
+// It enforces the limits of the Windows credential manager.
+func (t *Credential) SetAttribute(keyword string, value []byte) error {
+	if keyword == "" || len([]rune(keyword)) > MaxAttributeKeywordSize {
+		return fmt.Errorf("%w: %q", ErrInvalidKeyword, keyword)
+	}
+	if len(value) > MaxAttributeValueSize {
+		return fmt.Errorf("%w: %q has %d bytes", ErrAttributeTooLarge, keyword, len(value))
+	}
+	if t.attribute(keyword) == nil && len(t.Attributes) >= MaxAttributes {
+		return fmt.Errorf("%w: cannot add %q", ErrTooManyAttributes, keyword)
+	}
+	t.setAttribute(keyword, value)
+	return nil
+}
+
+// DeleteAttribute removes the attribute with the given keyword and reports
+// whether it existed.
+func (t *Credential) DeleteAttribute(keyword string) bool {
+	if t.attribute(keyword) == nil {
+		return false
+	}
+	t.removeAttribute(keyword)
+	return true
+}
+
+// requireAttribute returns the value of the attribute with the given keyword
+// or an error wrapping ErrElementNotFound.
+func (t *Credential) requireAttribute(keyword string) ([]byte, error) {
+	value, ok := t.GetAttribute(keyword)
+	if !ok {
+		return nil, fmt.Errorf("%w: attribute %q", ErrElementNotFound, keyword)
+	}
+	return value, nil
+}
+
+// SetAttributeString sets the attribute to the UTF-8 encoded string.
+func (t *Credential) SetAttributeString(keyword, value string) error {
+	return t.SetAttribute(keyword, []byte(value))
+}
+
+// GetAttributeString returns the value of the attribute as UTF-8 string.
+func (t *Credential) GetAttributeString(keyword string) (string, error) {
+	value, err := t.requireAttribute(keyword)
+	return string(value), err
+}
+
+// SetAttributeUTF16 sets the attribute to the UTF-16 little-endian encoded
+// string, the encoding used by most Windows applications.
+func (t *Credential) SetAttributeUTF16(keyword, value string) error {
+	return t.SetAttribute(keyword, encodeUTF16LE(value))
+}
+
+// GetAttributeUTF16 returns the value of the attribute decoded as UTF-16
+// little-endian string.
+func (t *Credential) GetAttributeUTF16(keyword string) (string, error) {
+	value, err := t.requireAttribute(keyword)
+	if err != nil {
+		return "", err
+	}
+	s, ok := decodeUTF16LE(value)
+	if !ok {
+		return "", fmt.Errorf("%w: attribute %q is not UTF-16", ErrInvalidEncoding, keyword)
+	}
+	return s, nil
+}
+
+// SetAttributeInt64 sets the attribute to the 8 byte little-endian
+// representation of the integer.
+func (t *Credential) SetAttributeInt64(keyword string, value int64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(value))
+	return t.SetAttribute(keyword, buf[:])
+}
+
+// GetAttributeInt64 returns the value of an attribute set with SetAttributeInt64.
+func (t *Credential) GetAttributeInt64(keyword string) (int64, error) {
+	value, err := t.requireAttribute(keyword)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) != 8 {
+		return 0, fmt.Errorf("%w: attribute %q is not a 64 bit integer", ErrInvalidEncoding, keyword)
+	}
+	return int64(binary.LittleEndian.Uint64(value)), nil
+}
+
+// SetAttributeBool sets the attribute to a single byte, 1 for true and 0 for false.
+func (t *Credential) SetAttributeBool(keyword string, value bool) error {
+	var b byte
+	if value {
+		b = 1
+	}
+	return t.SetAttribute(keyword, []byte{b})
+}
+
+// GetAttributeBool returns the value of an attribute set with SetAttributeBool.
+func (t *Credential) GetAttributeBool(keyword string) (bool, error) {
+	value, err := t.requireAttribute(keyword)
+	if err != nil {
+		return false, err
+	}
+	if len(value) != 1 || value[0] > 1 {
+		return false, fmt.Errorf("%w: attribute %q is not a boolean", ErrInvalidEncoding, keyword)
+	}
+	return value[0] == 1, nil
+}
+
+// SetAttributeTime sets the attribute to the time as 8 byte little-endian
+// FILETIME, i.e. in 100-nanosecond intervals since 1601-01-01 UTC. The zero
+// time is stored as FILETIME 0. Times outside the FILETIME range, roughly the
+// years -27626 to 30828, return an error wrapping ErrInvalidEncoding.
+func (t *Credential) SetAttributeTime(keyword string, value time.Time) error {
+	filetime, err := toFiletime(value)
+	if err != nil {
+		return err
+	}
+	return t.SetAttributeInt64(keyword, filetime)
+}
+
+// GetAttributeTime returns the value of an attribute set with SetAttributeTime.
+// FILETIME 0 results in the zero time.
+func (t *Credential) GetAttributeTime(keyword string) (time.Time, error) {
+	filetime, err := t.GetAttributeInt64(keyword)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fromFiletime(filetime), nil
+}
+
+// toFiletime converts the time to 100-nanosecond intervals since 1601-01-01 UTC.
+// The zero time is converted to 0. Seconds and nanoseconds are converted
+// separately, as UnixNano overflows outside the years 1678 to 2262.
+func toFiletime(value time.Time) (int64, error) {
+	if value.IsZero() {
+		return 0, nil
+	}
+	sec := value.Unix()
+	if sec < math.MinInt64/filetimeSecond-filetimeEpochSeconds+1 || sec > math.MaxInt64/filetimeSecond-filetimeEpochSeconds-1 {
+		return 0, fmt.Errorf("%w: %v is out of the FILETIME range", ErrInvalidEncoding, value)
+	}
+	return (sec+filetimeEpochSeconds)*filetimeSecond + int64(value.Nanosecond()/100), nil
+}
+
+// fromFiletime is the inverse of toFiletime.
+func fromFiletime(filetime int64) time.Time {
+	if filetime == 0 {
+		return time.Time{}
+	}
+	sec, rem := filetime/filetimeSecond, filetime%filetimeSecond
+	return time.Unix(sec-filetimeEpochSeconds, rem*100)
+}
+
+// SetAttributeJSON sets the attribute to the JSON encoding of the value.
+func (t *Credential) SetAttributeJSON(keyword string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return t.SetAttribute(keyword, data)
+}
+
+// GetAttributeJSON decodes the JSON value of the attribute into v.
+func (t *Credential) GetAttributeJSON(keyword string, v interface{}) error {
+	value, err := t.requireAttribute(keyword)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, v)
+}
+
+// clone returns a deep copy of the credential.
+func (t *Credential) clone() *Credential {
+	if t == nil {
+		return nil
+	}
+	result := *t
+	if t.CredentialBlob != nil {
+		result.CredentialBlob = append([]byte{}, t.CredentialBlob...)
+	}
+	if t.Attributes != nil {
+		result.Attributes = make([]CredentialAttribute, len(t.Attributes))
+		for i, attr := range t.Attributes {
+			result.Attributes[i].Keyword = attr.Keyword
+			if attr.Value != nil {
+				result.Attributes[i].Value = append([]byte{}, attr.Value...)
+			}
+		}
+	}
+	return &result
+}
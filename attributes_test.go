@@ -0,0 +1,109 @@
+package wincred
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredential_SetAttribute(t *testing.T) {
+	cred := new(Credential)
+	assert.Nil(t, cred.SetAttribute("host", []byte("a")))
+	assert.Nil(t, cred.SetAttribute("host", []byte("b")))
+	assert.Len(t, cred.Attributes, 1)
+	value, ok := cred.GetAttribute("host")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("b"), value)
+
+	assert.True(t, cred.DeleteAttribute("host"))
+	assert.False(t, cred.DeleteAttribute("host"))
+	_, ok = cred.GetAttribute("host")
+	assert.False(t, ok)
+}
+
+func TestCredential_SetAttribute_Limits(t *testing.T) {
+	cred := new(Credential)
+	assert.True(t, errors.Is(cred.SetAttribute("", nil), ErrInvalidKeyword))
+	assert.True(t, errors.Is(cred.SetAttribute(strings.Repeat("k", 257), nil), ErrInvalidKeyword))
+	assert.True(t, errors.Is(cred.SetAttribute("big", make([]byte, 257)), ErrAttributeTooLarge))
+	assert.Nil(t, cred.SetAttribute("max", make([]byte, 256)))
+	for i := 1; i < MaxAttributes; i++ {
+		assert.Nil(t, cred.SetAttribute(strconv.Itoa(i), nil))
+	}
+	assert.True(t, errors.Is(cred.SetAttribute("one-more", nil), ErrTooManyAttributes))
+	assert.Nil(t, cred.SetAttribute("max", nil))
+}
+
+func TestCredential_TypedAttributes(t *testing.T) {
+	cred := new(Credential)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	assert.Nil(t, cred.SetAttributeString("utf8", "grüße"))
+	assert.Nil(t, cred.SetAttributeUTF16("utf16", "grüße"))
+	assert.Nil(t, cred.SetAttributeInt64("int", -42))
+	assert.Nil(t, cred.SetAttributeBool("bool", true))
+	assert.Nil(t, cred.SetAttributeTime("time", now))
+	assert.Nil(t, cred.SetAttributeJSON("json", map[string]int{"port": 8080}))
+
+	s, err := cred.GetAttributeString("utf8")
+	assert.Nil(t, err)
+	assert.Equal(t, "grüße", s)
+	s, err = cred.GetAttributeUTF16("utf16")
+	assert.Nil(t, err)
+	assert.Equal(t, "grüße", s)
+	i, err := cred.GetAttributeInt64("int")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-42), i)
+	b, err := cred.GetAttributeBool("bool")
+	assert.Nil(t, err)
+	assert.True(t, b)
+	ts, err := cred.GetAttributeTime("time")
+	assert.Nil(t, err)
+	assert.True(t, now.Equal(ts))
+	var m map[string]int
+	assert.Nil(t, cred.GetAttributeJSON("json", &m))
+	assert.Equal(t, 8080, m["port"])
+
+	_, err = cred.GetAttributeInt64("bool")
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+	_, err = cred.GetAttributeString("missing")
+	assert.True(t, errors.Is(err, ErrElementNotFound))
+}
+
+func TestCredential_AttributeTime(t *testing.T) {
+	times := []time.Time{
+		{},
+		time.Date(1601, 1, 1, 0, 0, 0, 100, time.UTC),
+		time.Date(1000, 6, 7, 8, 9, 10, 1100, time.UTC),
+		time.Date(1677, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2262, 4, 12, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999900, time.UTC),
+		time.Date(30000, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, value := range times {
+		cred := new(Credential)
+		assert.Nil(t, cred.SetAttributeTime("time", value), value)
+		ts, err := cred.GetAttributeTime("time")
+		assert.Nil(t, err)
+		assert.True(t, value.Equal(ts), "%v != %v", value, ts)
+		assert.Equal(t, value.IsZero(), ts.IsZero(), value)
+	}
+
+	cred := new(Credential)
+	assert.Nil(t, cred.SetAttributeTime("time", time.Time{}))
+	i, err := cred.GetAttributeInt64("time")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), i)
+
+	assert.Nil(t, cred.SetAttributeTime("time", time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)))
+	i, _ = cred.GetAttributeInt64("time")
+	assert.Equal(t, int64(116444736000000000), i)
+
+	err = cred.SetAttributeTime("time", time.Date(40000, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+	err = cred.SetAttributeTime("time", time.Date(-40000, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+}
@@ -0,0 +1,268 @@
+package wincred
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// AttributeBlobCodec records the codecs a credential blob was encoded with,
+// as comma separated list of codec names in the order they were applied.
+const AttributeBlobCodec = "wincred:blob-codec"
+
+// Names of the built-in blob codecs.
+const (
+	BlobCodecDeflate = "deflate"
+	BlobCodecBase64  = "base64"
+	BlobCodecHex     = "hex"
+	BlobCodecAESGCM  = "aes-gcm"
+)
+
+// ErrUnknownBlobCodec is returned when decoding a blob with a codec that is
+// not registered.
+var ErrUnknownBlobCodec = errors.New("unknown blob codec")
+
+// BlobCodec is a reversible transformation of credential blobs.
+// Encode and Decode must not return their input slice, as intermediate
+// results of a pipeline are wiped.
+type BlobCodec interface {
+	// Name identifies the codec in the AttributeBlobCodec attribute.
+	// It must not contain commas.
+	Name() string
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+var blobCodecs = struct {
+	sync.RWMutex
+	codecs map[string]BlobCodec
+}{codecs: map[string]BlobCodec{
+	BlobCodecDeflate: deflateBlobCodec{},
+	BlobCodecBase64:  base64BlobCodec{},
+	BlobCodecHex:     hexBlobCodec{},
+}}
+
+// RegisterBlobCodec makes the codec available for decoding blobs by name,
+// replacing a codec of the same name. The deflate, base64 and hex codecs are
+// registered by default. Codecs with keys, like the one returned by
+// NewEncryptionBlobCodec, have to be registered by the application.
+// There is no built-in zstd codec, as the standard library has no zstd
+// implementation; applications can register one wrapping a third-party
+// library under the name "zstd".
+func RegisterBlobCodec(codec BlobCodec) {
+	blobCodecs.Lock()
+	defer blobCodecs.Unlock()
+	blobCodecs.codecs[codec.Name()] = codec
+}
+
+// LookupBlobCodec returns the registered codec with the given name.
+func LookupBlobCodec(name string) (BlobCodec, bool) {
+	blobCodecs.RLock()
+	defer blobCodecs.RUnlock()
+	codec, ok := blobCodecs.codecs[name]
+	return codec, ok
+}
+
+// BlobPipeline is a chain of blob codecs, applied in order when encoding.
+type BlobPipeline []BlobCodec
+
+// NewBlobPipeline returns a pipeline of the given codecs.
+func NewBlobPipeline(codecs ...BlobCodec) BlobPipeline {
+	return BlobPipeline(codecs)
+}
+
+// Encode encodes the data with all codecs of the pipeline, stores the result
+// as blob of the credential and records the pipeline in the
+// AttributeBlobCodec attribute. Intermediate and final results are wiped if
+// encoding fails; the input data is left to the caller.
+func (t BlobPipeline) Encode(cred *Credential, data []byte) error {
+	names := make([]string, len(t))
+	for i, codec := range t {
+		names[i] = codec.Name()
+		if names[i] == "" || strings.Contains(names[i], ",") {
+			return fmt.Errorf("%w: invalid name %q", ErrUnknownBlobCodec, names[i])
+		}
+	}
+	// wipe wipes the result, unless it is still the input.
+	wipe := func(result []byte) {
+		if len(t) > 0 {
+			wipeBytes(result)
+		}
+	}
+	for i, codec := range t {
+		encoded, err := codec.Encode(data)
+		if i > 0 {
+			wipeBytes(data)
+		}
+		if err != nil {
+			wipeBytes(encoded)
+			return fmt.Errorf("%s: %w", names[i], err)
+		}
+		data = encoded
+	}
+	if len(data) > MaxCredentialBlobSize {
+		wipe(data)
+		return fmt.Errorf("%w: %d bytes", ErrBlobTooLarge, len(data))
+	}
+	if err := cred.SetAttribute(AttributeBlobCodec, []byte(strings.Join(names, ","))); err != nil {
+		wipe(data)
+		return err
+	}
+	cred.CredentialBlob = data
+	return nil
+}
+
+// DecodeBlob returns the decoded blob of the credential, reversing the codecs
+// recorded in its AttributeBlobCodec attribute with the registered codecs.
+// A copy of the blob is returned if the credential has no such attribute, so
+// the result can always be wiped by the caller.
+func DecodeBlob(cred *Credential) ([]byte, error) {
+	attr, ok := cred.GetAttribute(AttributeBlobCodec)
+	if !ok || len(attr) == 0 {
+		return append([]byte{}, cred.CredentialBlob...), nil
+	}
+	names := strings.Split(string(attr), ",")
+	data := cred.CredentialBlob
+	for i := len(names) - 1; i >= 0; i-- {
+		codec, ok := LookupBlobCodec(names[i])
+		if !ok {
+			if i < len(names)-1 {
+				wipeBytes(data)
+			}
+			return nil, fmt.Errorf("%w: %q", ErrUnknownBlobCodec, names[i])
+		}
+		decoded, err := codec.Decode(data)
+		if i < len(names)-1 {
+			wipeBytes(data)
+		}
+		if err != nil {
+			wipeBytes(decoded)
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// maxInflatedBlobSize limits the size of decompressed blobs.
+const maxInflatedBlobSize = 1 << 20
+
+type deflateBlobCodec struct{}
+
+func (deflateBlobCodec) Name() string { return BlobCodecDeflate }
+
+func (deflateBlobCodec) Encode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (deflateBlobCodec) Decode(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	// Limit the output to protect against decompression bombs.
+	result, err := io.ReadAll(io.LimitReader(r, maxInflatedBlobSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	if len(result) > maxInflatedBlobSize {
+		return nil, fmt.Errorf("%w: inflated blob exceeds %d bytes", ErrBlobTooLarge, maxInflatedBlobSize)
+	}
+	return result, nil
+}
+
+type base64BlobCodec struct{}
+
+func (base64BlobCodec) Name() string { return BlobCodecBase64 }
+
+func (base64BlobCodec) Encode(data []byte) ([]byte, error) {
+	result := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(result, data)
+	return result, nil
+}
+
+func (base64BlobCodec) Decode(data []byte) ([]byte, error) {
+	result := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(result, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return result[:n], nil
+}
+
+type hexBlobCodec struct{}
+
+func (hexBlobCodec) Name() string { return BlobCodecHex }
+
+func (hexBlobCodec) Encode(data []byte) ([]byte, error) {
+	result := make([]byte, hex.EncodedLen(len(data)))
+	hex.Encode(result, data)
+	return result, nil
+}
+
+func (hexBlobCodec) Decode(data []byte) ([]byte, error) {
+	result := make([]byte, hex.DecodedLen(len(data)))
+	n, err := hex.Decode(result, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return result[:n], nil
+}
+
+// encryptionBlobCodec encrypts blobs with AES-GCM like Encryptor. The output
+// is the length of the key ID, the key ID and the output of sealValue. Unlike
+// Encryptor, the key ID is stored in the blob instead of the AttributeKeyID
+// attribute, as codecs only see the blob.
+type encryptionBlobCodec struct {
+	keys KeyProvider
+}
+
+// NewEncryptionBlobCodec returns a codec that encrypts blobs with AES-GCM
+// using the current key of the key provider. The ID of the key is stored with
+// the encrypted blob, so keys can be rotated.
+func NewEncryptionBlobCodec(keys KeyProvider) BlobCodec {
+	return encryptionBlobCodec{keys: keys}
+}
+
+func (encryptionBlobCodec) Name() string { return BlobCodecAESGCM }
+
+func (t encryptionBlobCodec) Encode(data []byte) ([]byte, error) {
+	id, aead, err := currentAEAD(t.keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("%w: key ID too long", ErrUnknownKey)
+	}
+	sealed, err := sealValue(aead, data, blobAdditionalData)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{byte(len(id))}, id...), sealed...), nil
+}
+
+func (t encryptionBlobCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data) < 1+int(data[0]) {
+		return nil, ErrDecryption
+	}
+	aead, err := keyAEAD(t.keys, string(data[1:1+int(data[0])]))
+	if err != nil {
+		return nil, err
+	}
+	return openValue(aead, data[1+int(data[0]):], blobAdditionalData)
+}
@@ -0,0 +1,116 @@
+package wincred
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobPipeline(t *testing.T) {
+	RegisterBlobCodec(NewEncryptionBlobCodec(fixtureKeys()))
+	defer func() {
+		blobCodecs.Lock()
+		delete(blobCodecs.codecs, BlobCodecAESGCM)
+		blobCodecs.Unlock()
+	}()
+	aesgcm, _ := LookupBlobCodec(BlobCodecAESGCM)
+	deflate, _ := LookupBlobCodec(BlobCodecDeflate)
+	base64, _ := LookupBlobCodec(BlobCodecBase64)
+
+	data := bytes.Repeat([]byte("near-limit payload "), 500)
+	cred := new(Credential)
+	err := NewBlobPipeline(deflate, aesgcm, base64).Encode(cred, data)
+	assert.Nil(t, err)
+	attr, _ := cred.GetAttribute(AttributeBlobCodec)
+	assert.Equal(t, "deflate,aes-gcm,base64", string(attr))
+	assert.Less(t, len(cred.CredentialBlob), MaxCredentialBlobSize)
+
+	decoded, err := DecodeBlob(cred)
+	assert.Nil(t, err)
+	assert.Equal(t, data, decoded)
+}
+
+func TestBlobPipeline_Hex(t *testing.T) {
+	hex, _ := LookupBlobCodec(BlobCodecHex)
+	cred := new(Credential)
+	assert.Nil(t, NewBlobPipeline(hex).Encode(cred, []byte{0xca, 0xfe}))
+	assert.Equal(t, []byte("cafe"), cred.CredentialBlob)
+	decoded, err := DecodeBlob(cred)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xca, 0xfe}, decoded)
+}
+
+func TestBlobPipeline_Errors(t *testing.T) {
+	cred := &Credential{CredentialBlob: []byte("plain")}
+	decoded, err := DecodeBlob(cred)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("plain"), decoded)
+	wipeBytes(decoded)
+	assert.Equal(t, []byte("plain"), cred.CredentialBlob)
+
+	cred.setAttribute(AttributeBlobCodec, []byte("zstd"))
+	_, err = DecodeBlob(cred)
+	assert.True(t, errors.Is(err, ErrUnknownBlobCodec))
+
+	cred.setAttribute(AttributeBlobCodec, []byte("base64"))
+	_, err = DecodeBlob(cred)
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+
+	base64, _ := LookupBlobCodec(BlobCodecBase64)
+	err = NewBlobPipeline(base64).Encode(cred, make([]byte, MaxCredentialBlobSize))
+	assert.True(t, errors.Is(err, ErrBlobTooLarge))
+}
+
+// failingBlobCodec records its outputs and fails when encoding or decoding
+// data of the given size.
+type failingBlobCodec struct {
+	name    string
+	failLen int
+	outputs *[][]byte
+}
+
+func (t failingBlobCodec) Name() string { return t.name }
+
+func (t failingBlobCodec) transform(data []byte) ([]byte, error) {
+	result := append([]byte{}, data...)
+	*t.outputs = append(*t.outputs, result)
+	if len(data) == t.failLen {
+		return nil, ErrInvalidEncoding
+	}
+	return result, nil
+}
+
+func (t failingBlobCodec) Encode(data []byte) ([]byte, error) { return t.transform(data) }
+func (t failingBlobCodec) Decode(data []byte) ([]byte, error) { return t.transform(data) }
+
+func TestBlobPipeline_WipeOnError(t *testing.T) {
+	var outputs [][]byte
+	first := failingBlobCodec{name: "first", failLen: -1, outputs: &outputs}
+	failing := failingBlobCodec{name: "failing", failLen: 6, outputs: &outputs}
+	cred := new(Credential)
+	data := []byte("secret")
+	err := NewBlobPipeline(first, failing).Encode(cred, data)
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+	assert.Equal(t, []byte("secret"), data)
+	assert.Equal(t, make([]byte, 6), outputs[0])
+	assert.Nil(t, cred.CredentialBlob)
+
+	outputs = nil
+	large := bytes.Repeat([]byte("x"), MaxCredentialBlobSize+1)
+	err = NewBlobPipeline(first).Encode(cred, large)
+	assert.True(t, errors.Is(err, ErrBlobTooLarge))
+	assert.Equal(t, make([]byte, len(large)), outputs[0])
+	assert.Equal(t, byte('x'), large[0])
+
+	outputs = nil
+	RegisterBlobCodec(first)
+	RegisterBlobCodec(failing)
+	cred = &Credential{CredentialBlob: []byte("secret")}
+	cred.setAttribute(AttributeBlobCodec, []byte("failing,first"))
+	_, err = DecodeBlob(cred)
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+	assert.Equal(t, make([]byte, 6), outputs[0])
+	assert.Equal(t, []byte("secret"), cred.CredentialBlob)
+}
@@ -0,0 +1,294 @@
+package wincred
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Bundles are JSON documents that carry a set of credentials, including their
+// secrets, encrypted with AES-256-GCM. The content key is either derived from a
+// passphrase (PBKDF2-HMAC-SHA256) or randomly generated and encrypted for an
+// RSA public key (RSA-OAEP-SHA256).
+const (
+	bundleFormat         = "wincred-bundle"
+	bundleVersion        = 1
+	bundleCipher         = "AES-256-GCM"
+	bundleKDF            = "PBKDF2-HMAC-SHA256"
+	bundleKeyWrap        = "RSA-OAEP-SHA256"
+	bundleKeySize        = 32
+	bundleSaltSize       = 16
+	bundleDataAdditional = bundleFormat
+
+	// DefaultBundleIterations is the default number of PBKDF2 iterations used
+	// for passphrase-encrypted bundles.
+	DefaultBundleIterations = 600000
+
+	// MaxBundleIterations is the maximum number of PBKDF2 iterations. Bundles
+	// with more iterations are rejected, so that a crafted bundle cannot keep
+	// the CPU busy.
+	MaxBundleIterations = 10000000
+)
+
+var (
+	// ErrBundleKey is returned if the bundle options do not specify exactly
+	// one way of protecting the bundle.
+	ErrBundleKey = errors.New("bundle requires either a passphrase or an RSA key")
+
+	// ErrInvalidBundle is returned when reading a malformed or unsupported bundle.
+	ErrInvalidBundle = errors.New("invalid credential bundle")
+)
+
+// BundleOptions controls how credential bundles are protected and imported.
+type BundleOptions struct {
+	// Passphrase protects the bundle with a key derived from it.
+	Passphrase []byte
+	// Iterations is the number of PBKDF2 iterations used when writing a
+	// passphrase-protected bundle. Defaults to DefaultBundleIterations; it
+	// must not exceed MaxBundleIterations.
+	Iterations int
+
+	// PublicKey protects the bundle for the owner of the matching private key.
+	// It is used for writing bundles.
+	PublicKey *rsa.PublicKey
+	// PrivateKey is used for reading bundles written for its public key.
+	PrivateKey *rsa.PrivateKey
+
+	// ImportOptions controls how bundle content is stored by Import.
+	ImportOptions
+}
+
+type bundleKDFParams struct {
+	Name       string `json:"name"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+}
+
+type bundleRecipient struct {
+	Algorithm    string `json:"algorithm"`
+	EncryptedKey []byte `json:"encryptedKey"`
+}
+
+// bundleEnvelope is the outer, unencrypted structure of a bundle.
+type bundleEnvelope struct {
+	Format    string           `json:"format"`
+	Version   int              `json:"version"`
+	Cipher    string           `json:"cipher"`
+	KDF       *bundleKDFParams `json:"kdf,omitempty"`
+	Recipient *bundleRecipient `json:"recipient,omitempty"`
+	Payload   []byte           `json:"payload"`
+}
+
+// bundlePayload is the encrypted content of a bundle.
+type bundlePayload struct {
+	Created     time.Time         `json:"created"`
+	Credentials []json.RawMessage `json:"credentials"`
+}
+
+// WriteBundle writes the given credentials, including their secrets, to an
+// encrypted bundle.
+func WriteBundle(w io.Writer, creds []*Credential, opts BundleOptions) error {
+	if (opts.Passphrase == nil) == (opts.PublicKey == nil) {
+		return ErrBundleKey
+	}
+	envelope := bundleEnvelope{Format: bundleFormat, Version: bundleVersion, Cipher: bundleCipher}
+	var key []byte
+	if opts.Passphrase != nil {
+		iterations := opts.Iterations
+		if iterations <= 0 {
+			iterations = DefaultBundleIterations
+		}
+		if iterations > MaxBundleIterations {
+			return fmt.Errorf("%w: more than %d iterations", ErrInvalidBundle, MaxBundleIterations)
+		}
+		salt := make([]byte, bundleSaltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return err
+		}
+		envelope.KDF = &bundleKDFParams{Name: bundleKDF, Iterations: iterations, Salt: salt}
+		key = pbkdf2SHA256(opts.Passphrase, salt, iterations, bundleKeySize)
+	} else {
+		key = make([]byte, bundleKeySize)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return err
+		}
+		encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, opts.PublicKey, key, nil)
+		if err != nil {
+			return err
+		}
+		envelope.Recipient = &bundleRecipient{Algorithm: bundleKeyWrap, EncryptedKey: encryptedKey}
+	}
+	defer wipeBytes(key)
+
+	payload := bundlePayload{Created: time.Now().UTC()}
+	for _, cred := range creds {
+		data, err := cred.MarshalJSONMode(SecretsInclude)
+		if err != nil {
+			return err
+		}
+		payload.Credentials = append(payload.Credentials, data)
+	}
+	plain, err := json.Marshal(payload)
+	for _, data := range payload.Credentials {
+		wipeBytes(data)
+	}
+	if err != nil {
+		return err
+	}
+	defer wipeBytes(plain)
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return err
+	}
+	if envelope.Payload, err = sealValue(aead, plain, bundleDataAdditional); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(envelope)
+}
+
+// ReadBundle reads and decrypts the credentials of a bundle written by
+// WriteBundle. The import options of the bundle options are ignored.
+func ReadBundle(r io.Reader, opts BundleOptions) ([]*Credential, error) {
+	var envelope bundleEnvelope
+	if err := json.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if envelope.Format != bundleFormat || envelope.Version != bundleVersion || envelope.Cipher != bundleCipher {
+		return nil, fmt.Errorf("%w: unsupported format %q version %d", ErrInvalidBundle, envelope.Format, envelope.Version)
+	}
+	var key []byte
+	switch {
+	case envelope.KDF != nil:
+		if envelope.KDF.Name != bundleKDF {
+			return nil, fmt.Errorf("%w: unsupported key derivation %q", ErrInvalidBundle, envelope.KDF.Name)
+		}
+		if envelope.KDF.Iterations <= 0 || envelope.KDF.Iterations > MaxBundleIterations {
+			return nil, fmt.Errorf("%w: invalid iteration count %d", ErrInvalidBundle, envelope.KDF.Iterations)
+		}
+		if len(envelope.KDF.Salt) != bundleSaltSize {
+			return nil, fmt.Errorf("%w: invalid salt size %d", ErrInvalidBundle, len(envelope.KDF.Salt))
+		}
+		if opts.Passphrase == nil {
+			return nil, ErrBundleKey
+		}
+		key = pbkdf2SHA256(opts.Passphrase, envelope.KDF.Salt, envelope.KDF.Iterations, bundleKeySize)
+	case envelope.Recipient != nil:
+		if envelope.Recipient.Algorithm != bundleKeyWrap {
+			return nil, fmt.Errorf("%w: unsupported key wrapping %q", ErrInvalidBundle, envelope.Recipient.Algorithm)
+		}
+		if opts.PrivateKey == nil {
+			return nil, ErrBundleKey
+		}
+		var err error
+		key, err = rsa.DecryptOAEP(sha256.New(), nil, opts.PrivateKey, envelope.Recipient.EncryptedKey, nil)
+		if err != nil {
+			return nil, ErrDecryption
+		}
+	default:
+		return nil, fmt.Errorf("%w: missing key information", ErrInvalidBundle)
+	}
+	defer wipeBytes(key)
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openValue(aead, envelope.Payload, bundleDataAdditional)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(plain)
+	var payload bundlePayload
+	// The raw messages are copies of the decrypted payload.
+	defer func() {
+		for _, data := range payload.Credentials {
+			wipeBytes(data)
+		}
+	}()
+	if err := json.Unmarshal(plain, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	creds := make([]*Credential, len(payload.Credentials))
+	for i, data := range payload.Credentials {
+		creds[i] = new(Credential)
+		if err := json.Unmarshal(data, creds[i]); err != nil {
+			wipeCredentials(creds[:i+1])
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+	}
+	return creds, nil
+}
+
+// Export writes all credentials matching the given filter (see FilteredList)
+// to an encrypted bundle. An empty filter exports all credentials.
+// Note that Windows does not reveal the secrets of domain credentials; they are
+// exported without credential blob and fail to import with ErrEmptyPassword
+// unless ImportOptions.AllowEmptyPasswords is set.
+func Export(w io.Writer, filter string, opts BundleOptions) error {
+	creds, err := listCredentials(filter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, cred := range creds {
+			cred.Wipe()
+		}
+	}()
+	return WriteBundle(w, creds, opts)
+}
+
+// Import reads an encrypted bundle and stores its credentials in Windows
+// credential manager. Existing credentials are handled according to the
+// conflict policy of the options.
+func Import(r io.Reader, opts BundleOptions) (*ImportReport, error) {
+	creds, err := ReadBundle(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, cred := range creds {
+			cred.Wipe()
+		}
+	}()
+	return importCredentials(creds, opts.ImportOptions), nil
+}
+
+// pbkdf2SHA256 derives a key from the given password as described in RFC 8018,
+// using HMAC-SHA256 as pseudorandom function.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	result := make([]byte, 0, (keyLen+hashLen-1)/hashLen*hashLen)
+	var counter [4]byte
+	u := make([]byte, hashLen)
+	for block := uint32(1); len(result) < keyLen; block++ {
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		result = append(result, t...)
+		wipeBytes(t)
+	}
+	wipeBytes(u)
+	return result[:keyLen]
+}
@@ -0,0 +1,100 @@
+package wincred
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureBundleCredentials() []*Credential {
+	return []*Credential{
+		fixtureSecretCredential(),
+		{TargetName: "Bar", UserName: "Nobody", Type: TypeDomainPassword, Persist: PersistEnterprise},
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// Test vectors from RFC 7914, section 11
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(key))
+	key = pbkdf2SHA256([]byte("password"), []byte("salt"), 2, 32)
+	assert.Equal(t, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43", hex.EncodeToString(key))
+}
+
+func TestBundle_Passphrase(t *testing.T) {
+	creds := fixtureBundleCredentials()
+	opts := BundleOptions{Passphrase: []byte("correct horse"), Iterations: 10}
+	var buf bytes.Buffer
+	err := WriteBundle(&buf, creds, opts)
+	assert.Nil(t, err)
+	assert.NotContains(t, buf.String(), "secret")
+	assert.Contains(t, buf.String(), `"name": "PBKDF2-HMAC-SHA256"`)
+
+	res, err := ReadBundle(bytes.NewReader(buf.Bytes()), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, creds[0].CredentialBlob, res[0].CredentialBlob)
+	assert.Equal(t, creds[0].Attributes, res[0].Attributes)
+	assert.Equal(t, TypeGeneric, res[0].Type)
+	assert.Equal(t, TypeDomainPassword, res[1].Type)
+	assert.Equal(t, PersistEnterprise, res[1].Persist)
+	assert.True(t, creds[0].LastWritten.Equal(res[0].LastWritten))
+
+	opts.Passphrase = []byte("wrong horse")
+	_, err = ReadBundle(bytes.NewReader(buf.Bytes()), opts)
+	assert.True(t, errors.Is(err, ErrDecryption))
+}
+
+func TestBundle_PublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	creds := fixtureBundleCredentials()
+	var buf bytes.Buffer
+	err = WriteBundle(&buf, creds, BundleOptions{PublicKey: &key.PublicKey})
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), `"algorithm": "RSA-OAEP-SHA256"`)
+
+	res, err := ReadBundle(bytes.NewReader(buf.Bytes()), BundleOptions{PrivateKey: key})
+	assert.Nil(t, err)
+	assert.Equal(t, creds[0].CredentialBlob, res[0].CredentialBlob)
+
+	_, err = ReadBundle(bytes.NewReader(buf.Bytes()), BundleOptions{Passphrase: []byte("x")})
+	assert.True(t, errors.Is(err, ErrBundleKey))
+}
+
+func TestWriteBundle_Key(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.True(t, errors.Is(WriteBundle(&buf, nil, BundleOptions{}), ErrBundleKey))
+	err = WriteBundle(&buf, nil, BundleOptions{Passphrase: []byte("x"), PublicKey: &key.PublicKey})
+	assert.True(t, errors.Is(err, ErrBundleKey))
+	err = WriteBundle(&buf, nil, BundleOptions{Passphrase: []byte("x"), Iterations: MaxBundleIterations + 1})
+	assert.True(t, errors.Is(err, ErrInvalidBundle))
+}
+
+func TestReadBundle_Invalid(t *testing.T) {
+	opts := BundleOptions{Passphrase: []byte("x")}
+	inputs := []string{
+		``,
+		`not json`,
+		`{"format":"other","version":1,"cipher":"AES-256-GCM"}`,
+		`{"format":"wincred-bundle","version":2,"cipher":"AES-256-GCM"}`,
+		`{"format":"wincred-bundle","version":1,"cipher":"AES-256-GCM"}`,
+		`{"format":"wincred-bundle","version":1,"cipher":"AES-256-GCM","kdf":{"name":"MD5","iterations":1}}`,
+		`{"format":"wincred-bundle","version":1,"cipher":"AES-256-GCM","kdf":{"name":"PBKDF2-HMAC-SHA256","iterations":0,"salt":"AAAAAAAAAAAAAAAAAAAAAA=="}}`,
+		`{"format":"wincred-bundle","version":1,"cipher":"AES-256-GCM","kdf":{"name":"PBKDF2-HMAC-SHA256","iterations":1000000000000,"salt":"AAAAAAAAAAAAAAAAAAAAAA=="}}`,
+		`{"format":"wincred-bundle","version":1,"cipher":"AES-256-GCM","kdf":{"name":"PBKDF2-HMAC-SHA256","iterations":1}}`,
+		`{"format":"wincred-bundle","version":1,"cipher":"AES-256-GCM","kdf":{"name":"PBKDF2-HMAC-SHA256","iterations":1,"salt":"AAAA"}}`,
+	}
+	for _, input := range inputs {
+		_, err := ReadBundle(strings.NewReader(input), opts)
+		assert.True(t, errors.Is(err, ErrInvalidBundle), input)
+	}
+}
@@ -0,0 +1,253 @@
+package wincred
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// CmdkeyLocale holds the localised labels and values printed by
+// `cmdkey /list`, so that its output can be parsed on non-English systems.
+type CmdkeyLocale struct {
+	// Target, Type and User are the labels of the respective lines,
+	// without colon.
+	Target string
+	Type   string
+	User   string
+	// Types maps the printed type names to credential types.
+	Types map[string]CredentialType
+	// Session and LocalMachine are the lines printed for credentials with
+	// session and local machine persistence. Credentials without such line
+	// have enterprise persistence.
+	Session      string
+	LocalMachine string
+}
+
+var cmdkeyLocales = struct {
+	sync.RWMutex
+	locales []*CmdkeyLocale
+}{locales: []*CmdkeyLocale{
+	{
+		Target: "Target",
+		Type:   "Type",
+		User:   "User",
+		Types: map[string]CredentialType{
+			"Generic":                     TypeGeneric,
+			"Domain Password":             TypeDomainPassword,
+			"Domain Certificate":          TypeDomainCertificate,
+			"Domain Visible Password":     TypeDomainVisiblePassword,
+			"Generic Certificate":         TypeGenericCertificate,
+			"Domain Extended Credentials": TypeDomainExtended,
+		},
+		Session:      "Saved for this logon only",
+		LocalMachine: "Local machine persistence",
+	},
+	{
+		Target: "Ziel",
+		Type:   "Typ",
+		User:   "Benutzer",
+		Types: map[string]CredentialType{
+			"Generisch":              TypeGeneric,
+			"Domänenkennwort":        TypeDomainPassword,
+			"Domänenzertifikat":      TypeDomainCertificate,
+			"Generisches Zertifikat": TypeGenericCertificate,
+		},
+		Session:      "Nur für diese Anmeldung gespeichert",
+		LocalMachine: "Lokale Computerbeibehaltung",
+	},
+	{
+		Target: "Cible",
+		Type:   "Type",
+		User:   "Utilisateur",
+		Types: map[string]CredentialType{
+			"Générique":               TypeGeneric,
+			"Mot de passe de domaine": TypeDomainPassword,
+			"Certificat de domaine":   TypeDomainCertificate,
+			"Certificat générique":    TypeGenericCertificate,
+		},
+		Session:      "Enregistré pour cette ouverture de session uniquement",
+		LocalMachine: "Persistance de l'ordinateur local",
+	},
+}}
+
+// RegisterCmdkeyLocale adds a localisation of the `cmdkey /list` output to
+// the ones recognised by ParseCmdkeyList. English, German and French are
+// supported by default.
+func RegisterCmdkeyLocale(locale *CmdkeyLocale) {
+	cmdkeyLocales.Lock()
+	defer cmdkeyLocales.Unlock()
+	cmdkeyLocales.locales = append(cmdkeyLocales.locales, locale)
+}
+
+// Prefixes cmdkey prints in front of target names that are not in the
+// "<scheme>:<key>=<value>" form.
+var cmdkeyTargetPrefixes = []string{"LegacyGeneric:target=", "Domain:target="}
+
+// cmdkeyLabel splits a line of the form "Label: value" (or "Label : value").
+func cmdkeyLabel(line string) (string, string, bool) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+// ParseCmdkeyList parses the output of `cmdkey /list` and returns the listed
+// credentials. Only the target name, type, user name and persistence are
+// available; the credential blobs are not part of the output.
+// Lines that cannot be interpreted are ignored.
+func ParseCmdkeyList(r io.Reader) ([]*Credential, error) {
+	cmdkeyLocales.RLock()
+	defer cmdkeyLocales.RUnlock()
+	var creds []*Credential
+	var current *Credential
+	var locale *CmdkeyLocale
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+		label, value, hasLabel := cmdkeyLabel(line)
+		if hasLabel {
+			if l := findCmdkeyLocale(label); l != nil {
+				locale = l
+				current = &Credential{TargetName: value, Persist: PersistEnterprise}
+				for _, prefix := range cmdkeyTargetPrefixes {
+					if strings.HasPrefix(value, prefix) {
+						current.TargetName = strings.TrimPrefix(value, prefix)
+						break
+					}
+				}
+				creds = append(creds, current)
+				continue
+			}
+		}
+		if current == nil {
+			continue
+		}
+		switch {
+		case hasLabel && label == locale.Type:
+			current.Type = locale.Types[value]
+		case hasLabel && label == locale.User:
+			if value != "<none>" {
+				current.UserName = value
+			}
+		case line == locale.LocalMachine:
+			current.Persist = PersistLocalMachine
+		case line == locale.Session:
+			current.Persist = PersistSession
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func findCmdkeyLocale(targetLabel string) *CmdkeyLocale {
+	for _, locale := range cmdkeyLocales.locales {
+		if locale.Target == targetLabel {
+			return locale
+		}
+	}
+	return nil
+}
+
+// ScriptFormat identifies the language of generated provisioning scripts.
+type ScriptFormat int
+
+const (
+	// ScriptCmd generates a Windows batch file.
+	ScriptCmd ScriptFormat = iota
+
+	// ScriptPowerShell generates a PowerShell script.
+	ScriptPowerShell
+)
+
+// ScriptOptions controls the generation of cmdkey scripts.
+type ScriptOptions struct {
+	// Format is the script language.
+	Format ScriptFormat
+
+	// IncludePasswords adds the decoded credential blobs as /pass arguments.
+	// Otherwise cmdkey prompts for the passwords when the script is run.
+	IncludePasswords bool
+}
+
+// WriteCmdkeyScript writes a script that recreates the given credentials with
+// cmdkey. Generic credentials are created with /generic, domain passwords with
+// /add. Other credential types are skipped with a comment.
+// Note that cmdkey does not support choosing the persistence mode, attributes
+// or comments.
+func WriteCmdkeyScript(w io.Writer, creds []*Credential, opts ScriptOptions) error {
+	quote := quoteCmdArg
+	comment := "REM "
+	bw := bufio.NewWriter(w)
+	switch opts.Format {
+	case ScriptCmd:
+		bw.WriteString("@echo off\r\n")
+	case ScriptPowerShell:
+		quote = quotePowerShellArg
+		comment = "# "
+	default:
+		return fmt.Errorf("unsupported script format %d", opts.Format)
+	}
+	newline := "\r\n"
+	for _, cred := range creds {
+		var option string
+		switch cred.Type {
+		case TypeGeneric, 0:
+			option = "/generic:"
+		case TypeDomainPassword:
+			option = "/add:"
+		default:
+			fmt.Fprintf(bw, "%sskipped %s credential %s%s", comment, cred.Type, strings.NewReplacer("\r", " ", "\n", " ").Replace(cred.TargetName), newline)
+			continue
+		}
+		args := []string{option + cred.TargetName}
+		if cred.UserName != "" {
+			args = append(args, "/user:"+cred.UserName)
+		}
+		if opts.IncludePasswords && len(cred.CredentialBlob) > 0 {
+			password, ok := decodeText(cred.CredentialBlob)
+			if !ok {
+				return fmt.Errorf("%w: credential blob of %q is not text", ErrInvalidEncoding, cred.TargetName)
+			}
+			args = append(args, "/pass:"+password)
+		}
+		line := []string{"cmdkey"}
+		if opts.Format == ScriptPowerShell {
+			line[0] = "& cmdkey.exe"
+		}
+		for _, arg := range args {
+			quoted, err := quote(arg)
+			if err != nil {
+				return fmt.Errorf("%w: %q", err, cred.TargetName)
+			}
+			line = append(line, quoted)
+		}
+		bw.WriteString(strings.Join(line, " ") + newline)
+	}
+	return bw.Flush()
+}
+
+// quoteCmdArg quotes an argument for a batch file. Double quotes and line
+// breaks cannot be represented.
+func quoteCmdArg(arg string) (string, error) {
+	if strings.ContainsAny(arg, "\"\r\n") {
+		return "", fmt.Errorf("%w: argument cannot be quoted for cmd", ErrInvalidEncoding)
+	}
+	return "\"" + strings.ReplaceAll(arg, "%", "%%") + "\"", nil
+}
+
+// quotePowerShellArg quotes an argument as verbatim PowerShell string.
+func quotePowerShellArg(arg string) (string, error) {
+	if strings.ContainsAny(arg, "\r\n") {
+		return "", fmt.Errorf("%w: argument cannot be quoted for PowerShell", ErrInvalidEncoding)
+	}
+	replacer := strings.NewReplacer("'", "''", "‘", "‘‘", "’", "’’")
+	return "'" + replacer.Replace(arg) + "'", nil
+}
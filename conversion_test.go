@@ -91,6 +91,13 @@ func TestConversion(t *testing.T) {
 	assert.NotEqual(t, cred.TargetName, res.TargetName)
 }
 
+func TestConversion_Type(t *testing.T) {
+	sys := sysFromCredential(fixtureCredential())
+	sys.Type = uint32(sysCRED_TYPE_DOMAIN_PASSWORD)
+	res := sysToCredential(sys)
+	assert.Equal(t, TypeDomainPassword, res.Type)
+}
+
 func TestConversion_Nil(t *testing.T) {
 	assert.NotPanics(t, func() {
 		res := sysToCredential(nil)
@@ -179,3 +186,14 @@ func BenchmarkConversionTo(b *testing.B) {
 		sysToCredential(n)
 	}
 }
+
+func TestSysWipeCredential(t *testing.T) {
+	cred := fixtureCredential()
+	cred.CredentialBlob = []byte("secret")
+	cred.Attributes = []CredentialAttribute{{Keyword: "Token", Value: []byte("abc")}}
+	sys := sysFromCredential(cred)
+	sysWipeCredential(sys)
+	assert.Equal(t, make([]byte, 6), cred.CredentialBlob)
+	assert.Equal(t, make([]byte, 3), cred.Attributes[0].Value)
+	assert.NotPanics(t, func() { sysWipeCredential(nil) })
+}
@@ -0,0 +1,149 @@
+package wincred
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DomainMatch describes how the target name of a domain credential matched a
+// host, ordered from the most to the least specific kind.
+type DomainMatch int
+
+const (
+	// DomainMatchExact indicates that the target name equals the host name.
+	DomainMatchExact DomainMatch = iota + 1
+
+	// DomainMatchService indicates that the target name is a service principal
+	// name, like "TERMSRV/server.contoso.com", for the host name or its NetBIOS
+	// name. Any service class matches.
+	DomainMatchService
+
+	// DomainMatchNetBIOS indicates that the target name equals the first label
+	// of the host name, i.e. its NetBIOS name.
+	DomainMatchNetBIOS
+
+	// DomainMatchWildcard indicates that the target name is a wildcard domain,
+	// like "*.contoso.com", containing the host.
+	DomainMatchWildcard
+
+	// DomainMatchDomain indicates that the target name is a NetBIOS domain
+	// wildcard, like "CONTOSO\*". The NetBIOS domain name of the host is
+	// assumed to be the second label of its DNS name, e.g. "contoso" for
+	// "server.contoso.com".
+	DomainMatchDomain
+
+	// DomainMatchSession indicates that the target name is the session
+	// wildcard "*Session", which applies to all hosts.
+	DomainMatchSession
+
+	// DomainMatchUniversal indicates that the target name is "*", which applies
+	// to all hosts.
+	DomainMatchUniversal
+)
+
+var domainMatchNames = map[DomainMatch]string{
+	DomainMatchExact:     "exact",
+	DomainMatchService:   "service",
+	DomainMatchNetBIOS:   "netbios",
+	DomainMatchWildcard:  "wildcard",
+	DomainMatchDomain:    "domain",
+	DomainMatchSession:   "session",
+	DomainMatchUniversal: "universal",
+}
+
+// String returns the name of the kind of match.
+func (t DomainMatch) String() string {
+	if name, ok := domainMatchNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("DomainMatch(%d)", int(t))
+}
+
+// sessionTarget is the target name of credentials that apply to all targets
+// of the logon session.
+const sessionTarget = "*Session"
+
+// MatchDomainTarget reports whether the target name of a domain credential
+// applies to the given host and how. It returns false if it does not apply.
+// The comparison is case-insensitive and ignores the "Domain:target=" prefix
+// as well as trailing dots.
+func MatchDomainTarget(targetName, host string) (DomainMatch, bool) {
+	match, _, ok := matchDomainTarget(targetName, host)
+	return match, ok
+}
+
+// matchDomainTarget additionally returns the length of the matched suffix,
+// which ranks wildcard matches among each other.
+func matchDomainTarget(targetName, host string) (DomainMatch, int, bool) {
+	if hasPrefixFold(targetName, "Domain:target=") {
+		targetName = targetName[len("Domain:target="):]
+	}
+	target := strings.ToLower(strings.TrimSuffix(targetName, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	switch {
+	case host == "" || target == "":
+		return 0, 0, false
+	case target == strings.ToLower(sessionTarget):
+		return DomainMatchSession, 0, true
+	case target == "*":
+		return DomainMatchUniversal, 0, true
+	case target == host:
+		return DomainMatchExact, len(target), true
+	case strings.Contains(target, "/"):
+		// Service principal names have the form "class/host[:port][/name]".
+		_, spnHost, _ := strings.Cut(target, "/")
+		spnHost, _, _ = strings.Cut(spnHost, "/")
+		spnHost, _, _ = strings.Cut(spnHost, ":")
+		spnHost = strings.TrimSuffix(spnHost, ".")
+		if spnHost == host || spnHost == netbiosName(host) {
+			return DomainMatchService, len(spnHost), true
+		}
+	case strings.HasPrefix(target, "*."):
+		suffix := target[1:]
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return DomainMatchWildcard, len(suffix), true
+		}
+	case strings.HasSuffix(target, `\*`):
+		domain := strings.TrimSuffix(target, `\*`)
+		if _, rest, ok := strings.Cut(host, "."); ok && domain != "" && domain == netbiosName(rest) {
+			return DomainMatchDomain, len(domain), true
+		}
+	case !strings.Contains(target, "."):
+		if target == netbiosName(host) {
+			return DomainMatchNetBIOS, len(target), true
+		}
+	}
+	return 0, 0, false
+}
+
+// netbiosName returns the first label of a DNS name, or an empty string if the
+// name has a single label.
+func netbiosName(name string) string {
+	if label, _, ok := strings.Cut(name, "."); ok {
+		return label
+	}
+	return ""
+}
+
+// SelectDomainPassword emulates how Windows picks a domain credential when
+// authenticating to the given host. It returns the most specific of the given
+// credentials and how it matched, or nil if none applies.
+// Exact host names are preferred over service principal names, NetBIOS names,
+// wildcard domains (the longest one first), NetBIOS domain wildcards, the
+// "*Session" credential and finally "*".
+// Among equally specific credentials the first one is chosen.
+func SelectDomainPassword(host string, creds []*DomainPassword) (*DomainPassword, DomainMatch) {
+	var best *DomainPassword
+	var bestMatch DomainMatch
+	bestLen := 0
+	for _, cred := range creds {
+		match, length, ok := matchDomainTarget(cred.TargetName, host)
+		if !ok {
+			continue
+		}
+		if best == nil || match < bestMatch || match == bestMatch && length > bestLen {
+			best, bestMatch, bestLen = cred, match, length
+		}
+	}
+	return best, bestMatch
+}
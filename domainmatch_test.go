@@ -0,0 +1,98 @@
+package wincred
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchDomainTarget(t *testing.T) {
+	tests := []struct {
+		target, host string
+		match        DomainMatch
+		ok           bool
+	}{
+		{"server.contoso.com", "SERVER.contoso.com.", DomainMatchExact, true},
+		{"Domain:target=server.contoso.com", "server.contoso.com", DomainMatchExact, true},
+		{"server", "server.contoso.com", DomainMatchNetBIOS, true},
+		{"*.contoso.com", "a.b.contoso.com", DomainMatchWildcard, true},
+		{"*.contoso.com", "contoso.com", 0, false},
+		{"*.contoso.com", "server.fabrikam.com", 0, false},
+		{"*Session", "anything", DomainMatchSession, true},
+		{"*", "anything", DomainMatchUniversal, true},
+		{"other", "server.contoso.com", 0, false},
+		{"TERMSRV/server.contoso.com", "server.contoso.com", DomainMatchService, true},
+		{"termsrv/SERVER", "server.contoso.com", DomainMatchService, true},
+		{"TERMSRV/server", "server", DomainMatchService, true},
+		{"HTTP/server.contoso.com:8080/app", "server.contoso.com", DomainMatchService, true},
+		{"TERMSRV/other.contoso.com", "server.contoso.com", 0, false},
+		{"TERMSRV/", "server.contoso.com", 0, false},
+		{`CONTOSO\*`, "server.contoso.com", DomainMatchDomain, true},
+		{`contoso\*`, "server.CONTOSO.com.", DomainMatchDomain, true},
+		{`FABRIKAM\*`, "server.contoso.com", 0, false},
+		{`CONTOSO\*`, "contoso", 0, false},
+		{`\*`, "server.contoso.com", 0, false},
+	}
+	for _, test := range tests {
+		match, ok := MatchDomainTarget(test.target, test.host)
+		assert.Equal(t, test.ok, ok, test.target)
+		assert.Equal(t, test.match, match, test.target)
+	}
+}
+
+func TestSelectDomainPassword(t *testing.T) {
+	creds := []*DomainPassword{
+		NewDomainPassword("*Session"),
+		NewDomainPassword("*.com"),
+		NewDomainPassword("*.contoso.com"),
+		NewDomainPassword("other.contoso.com"),
+	}
+	best, match := SelectDomainPassword("server.contoso.com", creds)
+	assert.Equal(t, creds[2], best)
+	assert.Equal(t, DomainMatchWildcard, match)
+
+	best, match = SelectDomainPassword("server.fabrikam.org", creds)
+	assert.Equal(t, creds[0], best)
+	assert.Equal(t, DomainMatchSession, match)
+
+	best, match = SelectDomainPassword("other.contoso.com", creds)
+	assert.Equal(t, creds[3], best)
+	assert.Equal(t, "exact", match.String())
+
+	best, _ = SelectDomainPassword("server.contoso.com", nil)
+	assert.Nil(t, best)
+}
+
+func TestSelectDomainPassword_Precedence(t *testing.T) {
+	creds := []*DomainPassword{
+		NewDomainPassword("*"),
+		NewDomainPassword("*Session"),
+		NewDomainPassword(`CONTOSO\*`),
+		NewDomainPassword("*.contoso.com"),
+		NewDomainPassword("server"),
+		NewDomainPassword("TERMSRV/server.contoso.com"),
+		NewDomainPassword("server.contoso.com"),
+	}
+	expected := []DomainMatch{
+		DomainMatchUniversal,
+		DomainMatchSession,
+		DomainMatchDomain,
+		DomainMatchWildcard,
+		DomainMatchNetBIOS,
+		DomainMatchService,
+		DomainMatchExact,
+	}
+	// Each credential is chosen over all less specific ones.
+	for i := range creds {
+		best, match := SelectDomainPassword("server.contoso.com", creds[:i+1])
+		assert.Equal(t, creds[i], best, creds[i].TargetName)
+		assert.Equal(t, expected[i], match, creds[i].TargetName)
+	}
+}
+
+func TestSelectDomainPassword_RDP(t *testing.T) {
+	creds := []*DomainPassword{NewDomainPassword(RDPTarget("jump01"))}
+	best, match := SelectDomainPassword("jump01", creds)
+	assert.Equal(t, creds[0], best)
+	assert.Equal(t, DomainMatchService, match)
+}
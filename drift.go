@@ -0,0 +1,334 @@
+package wincred
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidInventory is returned for malformed inventories.
+var ErrInvalidInventory = errors.New("invalid inventory")
+
+// Severity rates drift findings.
+type Severity int
+
+const (
+	// SeverityInfo is for findings that need no action.
+	SeverityInfo Severity = iota + 1
+
+	// SeverityWarning is for findings that should be looked at.
+	SeverityWarning
+
+	// SeverityCritical is for findings that violate the inventory.
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+// String returns the name of the severity.
+func (t Severity) String() string {
+	if name, ok := severityNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(t))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t Severity) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It accepts the names of the severities (case-insensitive).
+func (t *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severityNames {
+		if strings.EqualFold(name, string(text)) {
+			*t = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown severity %q", ErrInvalidInventory, text)
+}
+
+// Duration is a time.Duration that is represented as text like "720h" in
+// JSON and YAML.
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (t Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(t).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *Duration) UnmarshalText(text []byte) error {
+	d, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInventory, err)
+	}
+	*t = Duration(d)
+	return nil
+}
+
+// InventoryRule describes a set of approved credentials.
+type InventoryRule struct {
+	// Pattern matches the target names of the credentials of the rule. The
+	// wildcard "*" matches any characters; matching is case-insensitive.
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// Type restricts the rule to credentials of the given type, if set.
+	Type CredentialType `json:"type,omitempty" yaml:"type,omitempty"`
+	// Required demands at least one credential matching the rule, even if the
+	// credential is checked against an earlier rule.
+	Required bool `json:"required,omitempty" yaml:"required,omitempty"`
+	// Persist lists the allowed persistence modes. Any mode is allowed if
+	// empty.
+	Persist []CredentialPersistence `json:"persist,omitempty" yaml:"persist,omitempty"`
+	// Attributes lists the keywords of required attributes.
+	Attributes []string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	// MaxAge is the maximum time since the credential was last written. There
+	// is no limit if zero.
+	MaxAge Duration `json:"maxAge,omitempty" yaml:"maxAge,omitempty"`
+	// Severity overrides the severity of the findings of the rule.
+	Severity Severity `json:"severity,omitempty" yaml:"severity,omitempty"`
+}
+
+// Inventory describes the approved credentials of a machine. Each credential
+// is checked against the first rule it matches, but satisfies all required
+// rules it matches.
+type Inventory struct {
+	Rules []InventoryRule `json:"rules" yaml:"rules"`
+	// UnapprovedSeverity is the severity of credentials matching no rule,
+	// SeverityWarning if not set.
+	UnapprovedSeverity Severity `json:"unapprovedSeverity,omitempty" yaml:"unapprovedSeverity,omitempty"`
+}
+
+// ReadInventory decodes a JSON inventory. Unknown fields are rejected.
+func ReadInventory(r io.Reader) (*Inventory, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	inventory := new(Inventory)
+	if err := decoder.Decode(inventory); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInventory, err)
+	}
+	return inventory, nil
+}
+
+// FindingKind describes the kind of a drift finding.
+type FindingKind int
+
+const (
+	// FindingUnapproved is a credential that matches no rule.
+	FindingUnapproved FindingKind = iota + 1
+
+	// FindingMissing is a required rule without credential.
+	FindingMissing
+
+	// FindingPersistence is a credential with a persistence mode that is not
+	// allowed by its rule.
+	FindingPersistence
+
+	// FindingAttribute is a credential without an attribute required by its
+	// rule.
+	FindingAttribute
+
+	// FindingStale is a credential older than allowed by its rule.
+	FindingStale
+)
+
+var findingKindNames = map[FindingKind]string{
+	FindingUnapproved:  "unapproved",
+	FindingMissing:     "missing",
+	FindingPersistence: "persistence",
+	FindingAttribute:   "attribute",
+	FindingStale:       "stale",
+}
+
+// String returns the name of the kind.
+func (t FindingKind) String() string {
+	if name, ok := findingKindNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("FindingKind(%d)", int(t))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t FindingKind) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// DriftFinding is a deviation from the inventory.
+type DriftFinding struct {
+	Kind     FindingKind `json:"kind"`
+	Severity Severity    `json:"severity"`
+	// TargetName is the target name of the credential; it is empty for
+	// missing credentials.
+	TargetName string         `json:"targetName,omitempty"`
+	Type       CredentialType `json:"type,omitempty"`
+	// Rule is the pattern of the rule of the finding, if any.
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// DriftReport lists the deviations of the credential store from an
+// inventory. It is JSON serializable and never contains secrets.
+type DriftReport struct {
+	Time     time.Time      `json:"time"`
+	Checked  int            `json:"checked"`
+	Findings []DriftFinding `json:"findings"`
+}
+
+// Count returns the number of findings with the given severity.
+func (t *DriftReport) Count(severity Severity) int {
+	count := 0
+	for _, finding := range t.Findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// MaxSeverity returns the highest severity of the findings, or zero if there
+// are none.
+func (t *DriftReport) MaxSeverity() Severity {
+	var result Severity
+	for _, finding := range t.Findings {
+		if finding.Severity > result {
+			result = finding.Severity
+		}
+	}
+	return result
+}
+
+// matchPattern reports whether the target name matches the pattern, in which
+// "*" matches any characters.
+func matchPattern(pattern, targetName string) bool {
+	pattern, targetName = strings.ToLower(pattern), strings.ToLower(targetName)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == targetName
+	}
+	if !strings.HasPrefix(targetName, parts[0]) {
+		return false
+	}
+	rest := targetName[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return strings.HasSuffix(rest, parts[len(parts)-1])
+}
+
+// matches reports whether the credential belongs to the rule.
+func (t *InventoryRule) matches(cred *Credential) bool {
+	return (t.Type == 0 || t.Type == cred.Type) && matchPattern(t.Pattern, cred.TargetName)
+}
+
+func (t *InventoryRule) severity(fallback Severity) Severity {
+	if t.Severity != 0 {
+		return t.Severity
+	}
+	return fallback
+}
+
+// check returns the findings of a credential belonging to the rule.
+func (t *InventoryRule) check(cred *Credential, now time.Time) []DriftFinding {
+	var findings []DriftFinding
+	finding := func(kind FindingKind, severity Severity, format string, args ...interface{}) {
+		findings = append(findings, DriftFinding{
+			Kind:       kind,
+			Severity:   t.severity(severity),
+			TargetName: cred.TargetName,
+			Type:       cred.Type,
+			Rule:       t.Pattern,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+	if len(t.Persist) > 0 {
+		allowed := false
+		for _, persist := range t.Persist {
+			allowed = allowed || persist == cred.Persist
+		}
+		if !allowed {
+			finding(FindingPersistence, SeverityWarning, "persistence %v is not allowed", cred.Persist)
+		}
+	}
+	for _, keyword := range t.Attributes {
+		if _, ok := cred.GetAttribute(keyword); !ok {
+			finding(FindingAttribute, SeverityWarning, "attribute %q is missing", keyword)
+		}
+	}
+	if t.MaxAge > 0 && !cred.LastWritten.IsZero() {
+		if age := now.Sub(cred.LastWritten); age > time.Duration(t.MaxAge) {
+			finding(FindingStale, SeverityWarning, "last written %s ago, more than %s", age.Truncate(time.Second), time.Duration(t.MaxAge))
+		}
+	}
+	return findings
+}
+
+// checkDrift evaluates the credentials against the inventory.
+func checkDrift(inventory *Inventory, creds []*Credential, now time.Time) *DriftReport {
+	report := &DriftReport{Time: now, Checked: len(creds), Findings: []DriftFinding{}}
+	unapproved := inventory.UnapprovedSeverity
+	if unapproved == 0 {
+		unapproved = SeverityWarning
+	}
+	matched := make([]bool, len(inventory.Rules))
+	sorted := append([]*Credential{}, creds...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].TargetName < sorted[j].TargetName })
+	for _, cred := range sorted {
+		rule := -1
+		for i := range inventory.Rules {
+			if inventory.Rules[i].matches(cred) {
+				matched[i] = true
+				if rule < 0 {
+					rule = i
+				}
+			}
+		}
+		if rule < 0 {
+			report.Findings = append(report.Findings, DriftFinding{
+				Kind:       FindingUnapproved,
+				Severity:   unapproved,
+				TargetName: cred.TargetName,
+				Type:       cred.Type,
+				Message:    "credential is not in the inventory",
+			})
+			continue
+		}
+		report.Findings = append(report.Findings, inventory.Rules[rule].check(cred, now)...)
+	}
+	for i, rule := range inventory.Rules {
+		if rule.Required && !matched[i] {
+			report.Findings = append(report.Findings, DriftFinding{
+				Kind:     FindingMissing,
+				Severity: rule.severity(SeverityCritical),
+				Type:     rule.Type,
+				Rule:     rule.Pattern,
+				Message:  "required credential is missing",
+			})
+		}
+	}
+	return report
+}
+
+// CheckDrift evaluates all credentials of the current user against the
+// inventory and reports the deviations.
+func CheckDrift(inventory *Inventory) (*DriftReport, error) {
+	creds, err := List()
+	if err != nil {
+		return nil, err
+	}
+	defer wipeCredentials(creds)
+	return checkDrift(inventory, creds, time.Now()), nil
+}
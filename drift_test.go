@@ -0,0 +1,77 @@
+package wincred
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	assert.True(t, matchPattern("app/*", "App/db"))
+	assert.True(t, matchPattern("*", ""))
+	assert.True(t, matchPattern("git:*github.com*", "git:https://github.com/org"))
+	assert.True(t, matchPattern("a*b*c", "abc"))
+	assert.False(t, matchPattern("a*b*c", "acb"))
+	assert.False(t, matchPattern("app", "app/db"))
+	assert.False(t, matchPattern("*.example.com", "example.com"))
+}
+
+func TestReadInventory(t *testing.T) {
+	inventory, err := ReadInventory(strings.NewReader(`{
+		"rules": [{"pattern": "app/*", "persist": ["LocalMachine"], "maxAge": "720h", "severity": "critical"}],
+		"unapprovedSeverity": "info"
+	}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &Inventory{
+		Rules:              []InventoryRule{{Pattern: "app/*", Persist: []CredentialPersistence{PersistLocalMachine}, MaxAge: Duration(720 * time.Hour), Severity: SeverityCritical}},
+		UnapprovedSeverity: SeverityInfo,
+	}, inventory)
+
+	_, err = ReadInventory(strings.NewReader(`{"rules": [{"pattern": "*", "maxAge": "forever"}]}`))
+	assert.True(t, errors.Is(err, ErrInvalidInventory))
+}
+
+func TestCheckDrift(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	inventory := &Inventory{Rules: []InventoryRule{
+		{Pattern: "app/*", Type: TypeGeneric, Persist: []CredentialPersistence{PersistLocalMachine}, Attributes: []string{"owner"}, MaxAge: Duration(24 * time.Hour)},
+		{Pattern: "TERMSRV/*", Required: true},
+		{Pattern: "backup", Required: true, Severity: SeverityWarning},
+		{Pattern: "git:*"},
+		{Pattern: "git:https://github.com", Required: true},
+	}}
+	creds := []*Credential{
+		{TargetName: "app/ok", Type: TypeGeneric, Persist: PersistLocalMachine, LastWritten: now.Add(-time.Hour),
+			Attributes: []CredentialAttribute{{Keyword: "owner", Value: []byte("ops")}}, CredentialBlob: []byte("secret")},
+		{TargetName: "app/bad", Type: TypeGeneric, Persist: PersistSession, LastWritten: now.Add(-48 * time.Hour), CredentialBlob: []byte("secret")},
+		{TargetName: "git:https://github.com", Type: TypeGeneric},
+		{TargetName: "rogue", Type: TypeDomainPassword},
+	}
+	report := checkDrift(inventory, creds, now)
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, []DriftFinding{
+		{Kind: FindingPersistence, Severity: SeverityWarning, TargetName: "app/bad", Type: TypeGeneric, Rule: "app/*", Message: "persistence Session is not allowed"},
+		{Kind: FindingAttribute, Severity: SeverityWarning, TargetName: "app/bad", Type: TypeGeneric, Rule: "app/*", Message: `attribute "owner" is missing`},
+		{Kind: FindingStale, Severity: SeverityWarning, TargetName: "app/bad", Type: TypeGeneric, Rule: "app/*", Message: "last written 48h0m0s ago, more than 24h0m0s"},
+		{Kind: FindingUnapproved, Severity: SeverityWarning, TargetName: "rogue", Type: TypeDomainPassword, Message: "credential is not in the inventory"},
+		{Kind: FindingMissing, Severity: SeverityCritical, Rule: "TERMSRV/*", Message: "required credential is missing"},
+		{Kind: FindingMissing, Severity: SeverityWarning, Rule: "backup", Message: "required credential is missing"},
+	}, report.Findings)
+	assert.Equal(t, SeverityCritical, report.MaxSeverity())
+	assert.Equal(t, 5, report.Count(SeverityWarning))
+
+	data, err := json.Marshal(report)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), `"kind":"persistence","severity":"warning","targetName":"app/bad","type":"Generic"`)
+
+	report = checkDrift(&Inventory{}, nil, now)
+	assert.Equal(t, Severity(0), report.MaxSeverity())
+	data, err = json.Marshal(report)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"findings":[]`)
+}
@@ -0,0 +1,115 @@
+package wincred
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Text encodings that are recognized in credential blobs and attribute values.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF16LE = "utf-16le"
+	encodingBase64  = "base64"
+)
+
+// encodeUTF16LE encodes the given string as UTF-16 little-endian without
+// terminating null character.
+func encodeUTF16LE(s string) []byte {
+	chars := utf16.Encode([]rune(s))
+	defer wipeUint16(chars)
+	result := make([]byte, len(chars)*2)
+	for i, c := range chars {
+		binary.LittleEndian.PutUint16(result[i*2:], c)
+	}
+	return result
+}
+
+// decodeUTF16LE decodes the given UTF-16 little-endian bytes.
+// A single terminating null character is ignored.
+// It returns false if the bytes are not valid UTF-16.
+func decodeUTF16LE(b []byte) (string, bool) {
+	if len(b)%2 != 0 {
+		return "", false
+	}
+	chars := make([]uint16, len(b)/2)
+	defer wipeUint16(chars)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	if len(chars) > 0 && chars[len(chars)-1] == 0 {
+		chars = chars[:len(chars)-1]
+	}
+	runes := utf16.Decode(chars)
+	for _, r := range runes {
+		if r == utf8.RuneError {
+			return "", false
+		}
+	}
+	return string(runes), true
+}
+
+// isExactUTF16LE reports whether the given bytes are restored exactly by
+// decoding and re-encoding them, which is not the case if decodeUTF16LE drops
+// a terminating null character.
+func isExactUTF16LE(b []byte) bool {
+	s, ok := decodeUTF16LE(b)
+	if !ok {
+		return false
+	}
+	encoded := encodeUTF16LE(s)
+	defer wipeBytes(encoded)
+	return bytes.Equal(encoded, b)
+}
+
+// isPrintableText reports whether the given string only consists of
+// printable characters and white space.
+func isPrintableText(s string) bool {
+	for _, r := range s {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isMostlyLatin reports whether at least half of the characters of the given
+// string are from the Latin-1 range.
+func isMostlyLatin(s string) bool {
+	latin, total := 0, 0
+	for _, r := range s {
+		if r <= unicode.MaxLatin1 {
+			latin++
+		}
+		total++
+	}
+	return latin*2 >= total
+}
+
+// detectEncoding guesses the text encoding of the given bytes.
+// It returns encodingBase64 if the bytes do not look like UTF-8 or UTF-16 text.
+func detectEncoding(b []byte) string {
+	if len(b) == 0 || (utf8.Valid(b) && isPrintableText(string(b))) {
+		return encodingUTF8
+	}
+	// Short binary values easily decode to printable UTF-16 text. Since
+	// credentials mostly consist of Latin characters, require them to dominate.
+	if s, ok := decodeUTF16LE(b); ok && isPrintableText(s) && isMostlyLatin(s) {
+		return encodingUTF16LE
+	}
+	return encodingBase64
+}
+
+// decodeText decodes the given bytes as text, detecting UTF-8 and UTF-16
+// encodings. It returns false if the bytes are not text.
+func decodeText(b []byte) (string, bool) {
+	switch detectEncoding(b) {
+	case encodingUTF8:
+		return string(b), true
+	case encodingUTF16LE:
+		return decodeUTF16LE(b)
+	}
+	return "", false
+}
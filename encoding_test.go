@@ -0,0 +1,45 @@
+package wincred
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeUTF16LE(t *testing.T) {
+	assert.Equal(t, []byte{'a', 0, 'b', 0}, encodeUTF16LE("ab"))
+	assert.Equal(t, []byte{0x3d, 0xd8, 0x11, 0xdd}, encodeUTF16LE("\U0001f511"))
+	assert.Equal(t, []byte{}, encodeUTF16LE(""))
+}
+
+func TestDecodeUTF16LE(t *testing.T) {
+	s, ok := decodeUTF16LE([]byte{'a', 0, 'b', 0})
+	assert.True(t, ok)
+	assert.Equal(t, "ab", s)
+
+	s, ok = decodeUTF16LE([]byte{'a', 0, 0, 0})
+	assert.True(t, ok)
+	assert.Equal(t, "a", s)
+
+	_, ok = decodeUTF16LE([]byte{'a', 0, 'b'})
+	assert.False(t, ok)
+
+	_, ok = decodeUTF16LE([]byte{0x3d, 0xd8})
+	assert.False(t, ok)
+}
+
+func TestDetectEncoding(t *testing.T) {
+	assert.Equal(t, encodingUTF8, detectEncoding(nil))
+	assert.Equal(t, encodingUTF8, detectEncoding([]byte("my secret\n")))
+	assert.Equal(t, encodingUTF16LE, detectEncoding(encodeUTF16LE("my secret")))
+	assert.Equal(t, encodingBase64, detectEncoding([]byte{0xff, 0x00, 0x01}))
+	assert.Equal(t, encodingBase64, detectEncoding([]byte{0x01, 0x00}))
+}
+
+func TestDecodeText(t *testing.T) {
+	s, ok := decodeText(encodeUTF16LE("s3cr3t!"))
+	assert.True(t, ok)
+	assert.Equal(t, "s3cr3t!", s)
+	_, ok = decodeText([]byte{0xff, 0xfe, 0xfd})
+	assert.False(t, ok)
+}
@@ -0,0 +1,343 @@
+package wincred
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// AttributeKeyID is the keyword of the attribute that records the identifier
+	// of the key a sealed credential was encrypted with.
+	AttributeKeyID = "wincred:kid"
+
+	// AttributeAlgorithm is the keyword of the attribute that records the
+	// algorithm a sealed credential was encrypted with.
+	AttributeAlgorithm = "wincred:alg"
+
+	// AttributeSealed is the keyword of the attribute that lists the keywords of
+	// all attributes whose values were encrypted together with the credential blob.
+	AttributeSealed = "wincred:sealed"
+
+	// AlgorithmAESGCM identifies AES in Galois/Counter Mode with a random 96 bit nonce.
+	// The key size (128, 192 or 256 bits) is determined by the key provider.
+	AlgorithmAESGCM = "AES-GCM"
+)
+
+// blobAdditionalData is used as additional authenticated data when sealing the
+// credential blob. Attribute values are authenticated with their keyword, so
+// that sealed values cannot be swapped between fields.
+const blobAdditionalData = "CredentialBlob"
+
+var (
+	// ErrUnknownKey is returned by key providers if a requested key does not exist.
+	ErrUnknownKey = errors.New("unknown encryption key")
+
+	// ErrUnsupportedAlgorithm is returned when opening a credential that was
+	// sealed with an algorithm this package does not implement.
+	ErrUnsupportedAlgorithm = errors.New("unsupported encryption algorithm")
+
+	// ErrAlreadySealed is returned when trying to seal a credential twice.
+	ErrAlreadySealed = errors.New("credential is already sealed")
+
+	// ErrDecryption is returned if a sealed value cannot be decrypted, for
+	// example because it was tampered with or the key is wrong.
+	ErrDecryption = errors.New("credential decryption failed")
+)
+
+// KeyProvider supplies the symmetric keys used to seal and open credentials.
+// Keys are identified by an application-defined string, which is stored in
+// plain text next to the sealed credential.
+type KeyProvider interface {
+	// CurrentKey returns the identifier and value of the key that is used to
+	// seal new credentials.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the value of the key with the given identifier.
+	// It returns ErrUnknownKey if the key is not available.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider backed by an in-memory set of keys.
+type StaticKeys struct {
+	// Current is the identifier of the key used for sealing.
+	Current string
+	// Keys maps key identifiers to 16, 24 or 32 byte AES keys.
+	Keys map[string][]byte
+}
+
+// CurrentKey returns the key identified by the Current field.
+func (t *StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := t.Key(t.Current)
+	return t.Current, key, err
+}
+
+// Key returns the key with the given identifier.
+func (t *StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := t.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// Encryptor seals credential blobs and selected attribute values with AES-GCM
+// before they are written to the Windows credential manager and opens them
+// again after reading.
+// The key identifier and algorithm are recorded in the credential attributes.
+type Encryptor struct {
+	// Keys supplies the encryption keys.
+	Keys KeyProvider
+	// Attributes lists the keywords of attributes whose values are sealed
+	// in addition to the credential blob.
+	Attributes []string
+}
+
+// NewEncryptor creates an encryptor that uses the given key provider.
+// The values of attributes with the given keywords are sealed as well.
+func NewEncryptor(keys KeyProvider, attributes ...string) *Encryptor {
+	return &Encryptor{Keys: keys, Attributes: attributes}
+}
+
+// IsSealed reports whether the given credential was sealed by an Encryptor.
+func IsSealed(cred *Credential) bool {
+	return cred != nil && cred.attribute(AttributeAlgorithm) != nil
+}
+
+// Seal encrypts the credential blob and the configured attributes of the given
+// credential in place, using the current key of the key provider. It fails
+// with ErrBlobTooLarge, ErrAttributeTooLarge or ErrTooManyAttributes if the
+// sealed credential would exceed the limits of the credential manager.
+func (t *Encryptor) Seal(cred *Credential) error {
+	if IsSealed(cred) {
+		return ErrAlreadySealed
+	}
+	id, aead, err := currentAEAD(t.Keys)
+	if err != nil {
+		return err
+	}
+	blob, err := sealValue(aead, cred.CredentialBlob, blobAdditionalData)
+	if err != nil {
+		return err
+	}
+	if len(blob) > MaxCredentialBlobSize {
+		return fmt.Errorf("%w: sealed blob has %d bytes", ErrBlobTooLarge, len(blob))
+	}
+	var sealed []string
+	values := make(map[string][]byte)
+	for _, keyword := range t.Attributes {
+		if strings.Contains(keyword, ",") {
+			// The keywords are listed comma-separated in AttributeSealed
+			return fmt.Errorf("%w: %q contains a comma", ErrInvalidKeyword, keyword)
+		}
+		attr := cred.attribute(keyword)
+		if attr == nil {
+			continue
+		}
+		if values[keyword], err = sealValue(aead, attr.Value, keyword); err != nil {
+			return err
+		}
+		if len(values[keyword]) > MaxAttributeValueSize {
+			return fmt.Errorf("%w: sealed %q has %d bytes", ErrAttributeTooLarge, keyword, len(values[keyword]))
+		}
+		sealed = append(sealed, keyword)
+	}
+	metadata := map[string][]byte{
+		AttributeKeyID:     []byte(id),
+		AttributeAlgorithm: []byte(AlgorithmAESGCM),
+	}
+	if len(sealed) > 0 {
+		metadata[AttributeSealed] = []byte(strings.Join(sealed, ","))
+	}
+	count := len(cred.Attributes)
+	for keyword, value := range metadata {
+		if len(value) > MaxAttributeValueSize {
+			return fmt.Errorf("%w: %q has %d bytes", ErrAttributeTooLarge, keyword, len(value))
+		}
+		if cred.attribute(keyword) == nil {
+			count++
+		}
+	}
+	if count > MaxAttributes {
+		return fmt.Errorf("%w: sealing needs %d attributes", ErrTooManyAttributes, count)
+	}
+
+	// Only modify the credential once everything was encrypted successfully
+	cred.CredentialBlob = blob
+	for keyword, value := range values {
+		cred.setAttribute(keyword, value)
+	}
+	for _, keyword := range []string{AttributeKeyID, AttributeAlgorithm, AttributeSealed} {
+		if value, ok := metadata[keyword]; ok {
+			cred.setAttribute(keyword, value)
+		}
+	}
+	return nil
+}
+
+// Open decrypts a credential that was sealed with Seal in place and removes
+// the attributes describing the encryption.
+// Credentials that are not sealed are left untouched.
+func (t *Encryptor) Open(cred *Credential) error {
+	if !IsSealed(cred) {
+		return nil
+	}
+	if alg := string(cred.attribute(AttributeAlgorithm).Value); alg != AlgorithmAESGCM {
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	var id string
+	if attr := cred.attribute(AttributeKeyID); attr != nil {
+		id = string(attr.Value)
+	}
+	aead, err := keyAEAD(t.Keys, id)
+	if err != nil {
+		return err
+	}
+	blob, err := openValue(aead, cred.CredentialBlob, blobAdditionalData)
+	if err != nil {
+		return err
+	}
+	var sealed []string
+	if attr := cred.attribute(AttributeSealed); attr != nil && len(attr.Value) > 0 {
+		sealed = strings.Split(string(attr.Value), ",")
+	}
+	values := make(map[string][]byte)
+	for _, keyword := range sealed {
+		attr := cred.attribute(keyword)
+		if attr == nil {
+			return fmt.Errorf("%w: sealed attribute %q is missing", ErrDecryption, keyword)
+		}
+		if values[keyword], err = openValue(aead, attr.Value, keyword); err != nil {
+			return err
+		}
+	}
+
+	cred.CredentialBlob = blob
+	for keyword, value := range values {
+		cred.setAttribute(keyword, value)
+	}
+	cred.removeAttribute(AttributeKeyID)
+	cred.removeAttribute(AttributeAlgorithm)
+	cred.removeAttribute(AttributeSealed)
+	return nil
+}
+
+// Write seals a copy of the given generic credential and persists it to
+// Windows credential manager. The given credential object is not modified.
+func (t *Encryptor) Write(cred *GenericCredential) error {
+	sealed := &GenericCredential{Credential: *cred.Credential.clone()}
+	// Seal replaces the copied plain text values; wipe them once done
+	plain := sealed.Credential
+	plain.Attributes = append([]CredentialAttribute{}, sealed.Attributes...)
+	defer plain.Wipe()
+	if err := t.Seal(&sealed.Credential); err != nil {
+		return err
+	}
+	return sealed.Write()
+}
+
+// GetGenericCredential fetches the generic credential with the given name from
+// Windows credential manager and opens it if it is sealed.
+func (t *Encryptor) GetGenericCredential(targetName string) (*GenericCredential, error) {
+	cred, err := GetGenericCredential(targetName)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Open(&cred.Credential); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// Rotate re-encrypts all sealed generic credentials matching the given filter
+// (see FilteredList) that were not sealed with the current key.
+// It returns the number of credentials that were re-encrypted.
+func (t *Encryptor) Rotate(filter string) (int, error) {
+	id, _, err := t.Keys.CurrentKey()
+	if err != nil {
+		return 0, err
+	}
+	creds, err := FilteredList(filter)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, listed := range creds {
+		if !IsSealed(listed) {
+			continue
+		}
+		if attr := listed.attribute(AttributeKeyID); attr != nil && string(attr.Value) == id {
+			continue
+		}
+		cred, err := t.GetGenericCredential(listed.TargetName)
+		if err != nil {
+			if errors.Is(err, ErrElementNotFound) {
+				// Not a generic credential
+				continue
+			}
+			return count, err
+		}
+		err = t.Write(cred)
+		cred.Wipe()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// currentAEAD returns the identifier of the current key of the key provider
+// and the AES-GCM cipher using it.
+func currentAEAD(keys KeyProvider) (string, cipher.AEAD, error) {
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := newAESGCM(key)
+	return id, aead, err
+}
+
+// keyAEAD returns the AES-GCM cipher using the key with the given identifier.
+func keyAEAD(keys KeyProvider, id string) (cipher.AEAD, error) {
+	key, err := keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	return newAESGCM(key)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealValue encrypts the given plain text and returns the nonce followed by the
+// cipher text.
+func sealValue(aead cipher.AEAD, plain []byte, additionalData string) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, []byte(additionalData)), nil
+}
+
+// openValue decrypts a value created by sealValue.
+func openValue(aead cipher.AEAD, sealed []byte, additionalData string) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return plain, nil
+}
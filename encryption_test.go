@@ -0,0 +1,141 @@
+package wincred
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureKeys() *StaticKeys {
+	return &StaticKeys{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef0123456789abcdef"),
+			"k2": []byte("fedcba9876543210"),
+		},
+	}
+}
+
+func TestEncryptor_SealOpen(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys(), "Token")
+	cred := &Credential{
+		TargetName:     "Foo",
+		CredentialBlob: []byte("my secret"),
+		Attributes: []CredentialAttribute{
+			{Keyword: "Token", Value: []byte("abc")},
+			{Keyword: "Plain", Value: []byte("visible")},
+		},
+	}
+	err := enc.Seal(cred)
+	assert.Nil(t, err)
+	assert.True(t, IsSealed(cred))
+	assert.NotEqual(t, []byte("my secret"), cred.CredentialBlob)
+	assert.NotEqual(t, []byte("abc"), cred.attribute("Token").Value)
+	assert.Equal(t, []byte("visible"), cred.attribute("Plain").Value)
+	assert.Equal(t, []byte("k1"), cred.attribute(AttributeKeyID).Value)
+	assert.Equal(t, []byte(AlgorithmAESGCM), cred.attribute(AttributeAlgorithm).Value)
+	assert.Equal(t, []byte("Token"), cred.attribute(AttributeSealed).Value)
+
+	err = enc.Open(cred)
+	assert.Nil(t, err)
+	assert.False(t, IsSealed(cred))
+	assert.Equal(t, []byte("my secret"), cred.CredentialBlob)
+	assert.Equal(t, []CredentialAttribute{
+		{Keyword: "Token", Value: []byte("abc")},
+		{Keyword: "Plain", Value: []byte("visible")},
+	}, cred.Attributes)
+}
+
+func TestEncryptor_SealTwice(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys())
+	cred := &Credential{CredentialBlob: []byte("my secret")}
+	assert.Nil(t, enc.Seal(cred))
+	assert.True(t, errors.Is(enc.Seal(cred), ErrAlreadySealed))
+}
+
+func TestEncryptor_OpenUnsealed(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys())
+	cred := &Credential{CredentialBlob: []byte("my secret")}
+	assert.Nil(t, enc.Open(cred))
+	assert.Equal(t, []byte("my secret"), cred.CredentialBlob)
+}
+
+func TestEncryptor_OpenRotatedKey(t *testing.T) {
+	keys := fixtureKeys()
+	enc := NewEncryptor(keys)
+	cred := &Credential{CredentialBlob: []byte("my secret")}
+	assert.Nil(t, enc.Seal(cred))
+	keys.Current = "k2"
+	assert.Nil(t, enc.Open(cred))
+	assert.Equal(t, []byte("my secret"), cred.CredentialBlob)
+}
+
+func TestEncryptor_OpenUnknownKey(t *testing.T) {
+	keys := fixtureKeys()
+	enc := NewEncryptor(keys)
+	cred := &Credential{CredentialBlob: []byte("my secret")}
+	assert.Nil(t, enc.Seal(cred))
+	delete(keys.Keys, "k1")
+	assert.True(t, errors.Is(enc.Open(cred), ErrUnknownKey))
+	assert.True(t, IsSealed(cred))
+}
+
+func TestEncryptor_OpenTampered(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys(), "Token")
+	cred := &Credential{
+		CredentialBlob: []byte("my secret"),
+		Attributes:     []CredentialAttribute{{Keyword: "Token", Value: []byte("abc")}},
+	}
+	assert.Nil(t, enc.Seal(cred))
+	cred.CredentialBlob[len(cred.CredentialBlob)-1] ^= 0xff
+	assert.True(t, errors.Is(enc.Open(cred), ErrDecryption))
+}
+
+func TestEncryptor_OpenSwappedValues(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys(), "Token")
+	cred := &Credential{
+		CredentialBlob: []byte("my secret"),
+		Attributes:     []CredentialAttribute{{Keyword: "Token", Value: []byte("abc")}},
+	}
+	assert.Nil(t, enc.Seal(cred))
+	attr := cred.attribute("Token")
+	cred.CredentialBlob, attr.Value = attr.Value, cred.CredentialBlob
+	assert.True(t, errors.Is(enc.Open(cred), ErrDecryption))
+}
+
+func TestEncryptor_OpenUnsupportedAlgorithm(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys())
+	cred := &Credential{CredentialBlob: []byte("my secret")}
+	assert.Nil(t, enc.Seal(cred))
+	cred.setAttribute(AttributeAlgorithm, []byte("ROT13"))
+	assert.True(t, errors.Is(enc.Open(cred), ErrUnsupportedAlgorithm))
+}
+
+func TestEncryptor_SealEmpty(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys())
+	cred := &Credential{}
+	assert.Nil(t, enc.Seal(cred))
+	assert.Nil(t, enc.Open(cred))
+	assert.Empty(t, cred.CredentialBlob)
+}
+
+func TestEncryptor_SealLimits(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys(), "Token")
+	cred := &Credential{CredentialBlob: make([]byte, MaxCredentialBlobSize-27)}
+	assert.True(t, errors.Is(enc.Seal(cred), ErrBlobTooLarge))
+	assert.False(t, IsSealed(cred))
+
+	cred = &Credential{Attributes: []CredentialAttribute{{Keyword: "Token", Value: make([]byte, MaxAttributeValueSize-27)}}}
+	assert.True(t, errors.Is(enc.Seal(cred), ErrAttributeTooLarge))
+
+	cred = &Credential{Attributes: make([]CredentialAttribute, MaxAttributes-1)}
+	assert.True(t, errors.Is(enc.Seal(cred), ErrTooManyAttributes))
+	assert.Len(t, cred.Attributes, MaxAttributes-1)
+
+	cred = &Credential{CredentialBlob: make([]byte, MaxCredentialBlobSize-28)}
+	assert.Nil(t, enc.Seal(cred))
+
+	enc = NewEncryptor(fixtureKeys(), "a,b")
+	assert.True(t, errors.Is(enc.Seal(&Credential{}), ErrInvalidKeyword))
+}
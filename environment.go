@@ -0,0 +1,200 @@
+package wincred
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Environment is a variant of a set of credentials, like dev, staging or
+// prod, stored under its own target name prefix. Prefixes should end with a
+// separator, e.g. "dev/", so that no prefix is a prefix of another.
+type Environment struct {
+	Name   string
+	Prefix string
+}
+
+// DiffStatus describes how a credential differs between two environments.
+type DiffStatus int
+
+const (
+	// DiffMissing indicates that the credential exists only in the source
+	// environment.
+	DiffMissing DiffStatus = iota + 1
+
+	// DiffExtra indicates that the credential exists only in the target
+	// environment.
+	DiffExtra
+
+	// DiffChanged indicates that the credential exists in both environments
+	// with different content.
+	DiffChanged
+
+	// DiffEqual indicates that the credential is the same in both environments.
+	DiffEqual
+)
+
+var diffStatusNames = map[DiffStatus]string{
+	DiffMissing: "missing",
+	DiffExtra:   "extra",
+	DiffChanged: "changed",
+	DiffEqual:   "equal",
+}
+
+// String returns the name of the status.
+func (t DiffStatus) String() string {
+	if name, ok := diffStatusNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("DiffStatus(%d)", int(t))
+}
+
+// EnvironmentDiff describes the difference of a credential between two
+// environments. It never contains secrets; a changed credential blob is only
+// listed by its field name.
+type EnvironmentDiff struct {
+	// Key is the target name without environment prefix.
+	Key    string
+	Type   CredentialType
+	Status DiffStatus
+	// Changes lists the names of the differing fields of changed credentials,
+	// e.g. "UserName", "CredentialBlob" or "Attributes[host]".
+	Changes []string
+}
+
+// String returns a one-line description of the difference.
+func (t EnvironmentDiff) String() string {
+	if len(t.Changes) == 0 {
+		return fmt.Sprintf("%s %s %s", t.Status, t.Type, t.Key)
+	}
+	return fmt.Sprintf("%s %s %s (%s)", t.Status, t.Type, t.Key, strings.Join(t.Changes, ", "))
+}
+
+// environmentKey identifies a credential within an environment.
+type environmentKey struct {
+	key string
+	typ CredentialType
+}
+
+// environmentCredentials returns the credentials of the environment by key.
+// The prefix is compared case-insensitively, like Windows compares target
+// names.
+func environmentCredentials(prefix string, creds []*Credential) map[environmentKey]*Credential {
+	result := make(map[environmentKey]*Credential)
+	for _, cred := range creds {
+		if hasPrefixFold(cred.TargetName, prefix) {
+			result[environmentKey{cred.TargetName[len(prefix):], cred.Type}] = cred
+		}
+	}
+	return result
+}
+
+// credentialChanges returns the names of the fields that differ between the
+// credentials. Target names and timestamps are not compared.
+func credentialChanges(a, b *Credential) []string {
+	var changes []string
+	if a.UserName != b.UserName {
+		changes = append(changes, "UserName")
+	}
+	if a.Comment != b.Comment {
+		changes = append(changes, "Comment")
+	}
+	if a.Persist != b.Persist {
+		changes = append(changes, "Persist")
+	}
+	if sha256.Sum256(a.CredentialBlob) != sha256.Sum256(b.CredentialBlob) {
+		changes = append(changes, "CredentialBlob")
+	}
+	keywords := make(map[string]bool)
+	for _, attr := range append(append([]CredentialAttribute{}, a.Attributes...), b.Attributes...) {
+		keywords[attr.Keyword] = true
+	}
+	var attrChanges []string
+	for keyword := range keywords {
+		va, okA := a.GetAttribute(keyword)
+		vb, okB := b.GetAttribute(keyword)
+		if okA != okB || !bytes.Equal(va, vb) {
+			attrChanges = append(attrChanges, "Attributes["+keyword+"]")
+		}
+	}
+	sort.Strings(attrChanges)
+	return append(changes, attrChanges...)
+}
+
+// compareEnvironments compares the credentials of two environments.
+func compareEnvironments(from, to Environment, creds []*Credential) []EnvironmentDiff {
+	source := environmentCredentials(from.Prefix, creds)
+	target := environmentCredentials(to.Prefix, creds)
+	var diffs []EnvironmentDiff
+	for key, cred := range source {
+		diff := EnvironmentDiff{Key: key.key, Type: key.typ, Status: DiffMissing}
+		if other, ok := target[key]; ok {
+			diff.Changes = credentialChanges(cred, other)
+			diff.Status = DiffEqual
+			if len(diff.Changes) > 0 {
+				diff.Status = DiffChanged
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	for key := range target {
+		if _, ok := source[key]; !ok {
+			diffs = append(diffs, EnvironmentDiff{Key: key.key, Type: key.typ, Status: DiffExtra})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Key != diffs[j].Key {
+			return diffs[i].Key < diffs[j].Key
+		}
+		return diffs[i].Type < diffs[j].Type
+	})
+	return diffs
+}
+
+// listEnvironments lists the credentials of the given environments.
+func listEnvironments(envs ...Environment) ([]*Credential, error) {
+	var creds []*Credential
+	for _, env := range envs {
+		list, err := listPrefix(env.Prefix)
+		if err != nil {
+			wipeCredentials(creds)
+			return nil, err
+		}
+		creds = append(creds, list...)
+	}
+	return creds, nil
+}
+
+// CompareEnvironments compares the credentials of two environments and
+// returns the differences, sorted by key. Note that Windows does not reveal
+// the secrets of domain credentials, so their blobs always compare equal.
+func CompareEnvironments(from, to Environment) ([]EnvironmentDiff, error) {
+	creds, err := listEnvironments(from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeCredentials(creds)
+	return compareEnvironments(from, to, creds), nil
+}
+
+// Promote copies the generic credentials with the given keys from one
+// environment to another. Keys without credential in the source environment
+// are reported as failed. Existing credentials are handled according to the
+// conflict policy of the options; with DryRun set, the report only lists what
+// would be done. Domain credentials cannot be promoted, as Windows does not
+// reveal their secrets.
+func Promote(from, to Environment, keys []string, opts ImportOptions) *ImportReport {
+	report := new(ImportReport)
+	for _, key := range keys {
+		cred, err := GetGenericCredential(from.Prefix + key)
+		if err != nil {
+			report.Results = append(report.Results, ImportResult{TargetName: to.Prefix + key, Type: TypeGeneric, Action: ImportFailed, Err: err})
+			continue
+		}
+		cred.TargetName = to.Prefix + key
+		report.Results = append(report.Results, importCredential(&cred.Credential, opts))
+	}
+	return report
+}
@@ -0,0 +1,52 @@
+package wincred
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareEnvironments(t *testing.T) {
+	dev := Environment{Name: "dev", Prefix: "dev/"}
+	prod := Environment{Name: "prod", Prefix: "prod/"}
+	creds := []*Credential{
+		{TargetName: "dev/db", UserName: "admin", CredentialBlob: []byte("dev-secret"), Type: TypeGeneric,
+			Attributes: []CredentialAttribute{{Keyword: "host", Value: []byte("dev-db")}}},
+		{TargetName: "prod/db", UserName: "admin", CredentialBlob: []byte("prod-secret"), Type: TypeGeneric,
+			Attributes: []CredentialAttribute{{Keyword: "host", Value: []byte("prod-db")}, {Keyword: "port", Value: []byte("5432")}}},
+		{TargetName: "dev/cache", Type: TypeGeneric},
+		{TargetName: "dev/api", Comment: "same", Type: TypeGeneric},
+		{TargetName: "prod/api", Comment: "same", Type: TypeGeneric},
+		{TargetName: "prod/legacy", Type: TypeDomainPassword},
+		{TargetName: "staging/db", Type: TypeGeneric},
+	}
+	diffs := compareEnvironments(dev, prod, creds)
+	assert.Equal(t, []EnvironmentDiff{
+		{Key: "api", Type: TypeGeneric, Status: DiffEqual},
+		{Key: "cache", Type: TypeGeneric, Status: DiffMissing},
+		{Key: "db", Type: TypeGeneric, Status: DiffChanged, Changes: []string{"CredentialBlob", "Attributes[host]", "Attributes[port]"}},
+		{Key: "legacy", Type: TypeDomainPassword, Status: DiffExtra},
+	}, diffs)
+
+	for _, diff := range diffs {
+		assert.NotContains(t, diff.String(), "secret")
+		assert.NotContains(t, fmt.Sprintf("%+v", diff), "secret")
+	}
+	assert.Equal(t, "changed Generic db (CredentialBlob, Attributes[host], Attributes[port])", diffs[2].String())
+}
+
+func TestCompareEnvironments_MixedCase(t *testing.T) {
+	dev := Environment{Name: "dev", Prefix: "dev/"}
+	prod := Environment{Name: "prod", Prefix: "Prod/"}
+	creds := []*Credential{
+		{TargetName: "Dev/db", Type: TypeGeneric},
+		{TargetName: "DEV/cache", Type: TypeGeneric},
+		{TargetName: "prod/db", Type: TypeGeneric},
+	}
+	diffs := compareEnvironments(dev, prod, creds)
+	assert.Equal(t, []EnvironmentDiff{
+		{Key: "cache", Type: TypeGeneric, Status: DiffMissing},
+		{Key: "db", Type: TypeGeneric, Status: DiffEqual},
+	}, diffs)
+}
@@ -0,0 +1,201 @@
+package wincred
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sensitiveAttributes holds the keywords of attributes whose values are
+// redacted like the credential blob when printing or logging a credential.
+var sensitiveAttributes = struct {
+	sync.RWMutex
+	keywords map[string]bool
+}{keywords: make(map[string]bool)}
+
+// MarkSensitiveAttribute marks attributes with the given keywords as sensitive.
+// The values of sensitive attributes are redacted when a credential is
+// printed, formatted or logged.
+func MarkSensitiveAttribute(keywords ...string) {
+	sensitiveAttributes.Lock()
+	defer sensitiveAttributes.Unlock()
+	for _, keyword := range keywords {
+		sensitiveAttributes.keywords[keyword] = true
+	}
+}
+
+// UnmarkSensitiveAttribute removes the sensitive mark of the attributes with
+// the given keywords.
+func UnmarkSensitiveAttribute(keywords ...string) {
+	sensitiveAttributes.Lock()
+	defer sensitiveAttributes.Unlock()
+	for _, keyword := range keywords {
+		delete(sensitiveAttributes.keywords, keyword)
+	}
+}
+
+// IsSensitiveAttribute reports whether the attribute with the given keyword
+// was marked as sensitive.
+func IsSensitiveAttribute(keyword string) bool {
+	sensitiveAttributes.RLock()
+	defer sensitiveAttributes.RUnlock()
+	return sensitiveAttributes.keywords[keyword]
+}
+
+// redactKey is the random key of the fingerprints of redacted secrets. It
+// changes with every process, so the fingerprints cannot be used to guess
+// secrets offline.
+var redactKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// redact returns a placeholder for the given secret that only reveals its
+// length and a short fingerprint of its content, which is only comparable
+// within the same process.
+func redact(secret []byte) string {
+	if len(secret) == 0 {
+		return "[REDACTED len=0]"
+	}
+	mac := hmac.New(sha256.New, redactKey)
+	mac.Write(secret)
+	return fmt.Sprintf("[REDACTED len=%d hmac=%s]", len(secret), hex.EncodeToString(mac.Sum(nil)[:4]))
+}
+
+// attributeValueString returns the printable representation of an attribute value.
+func attributeValueString(attr CredentialAttribute) string {
+	if IsSensitiveAttribute(attr.Keyword) {
+		return redact(attr.Value)
+	}
+	return fmt.Sprint(attr.Value)
+}
+
+// String implements fmt.Stringer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t Credential) String() string {
+	return t.format(false)
+}
+
+// GoString implements fmt.GoStringer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t Credential) GoString() string {
+	return "wincred.Credential" + t.goSyntax()
+}
+
+// Format implements fmt.Formatter, so that no formatting verb reveals the
+// credential blob or the values of sensitive attributes.
+func (t Credential) Format(f fmt.State, verb rune) {
+	formatCredential(f, verb, &t, t.GoString)
+}
+
+// String implements fmt.Stringer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t GenericCredential) String() string {
+	return t.Credential.String()
+}
+
+// GoString implements fmt.GoStringer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t GenericCredential) GoString() string {
+	return "wincred.GenericCredential{Credential:" + t.Credential.GoString() + "}"
+}
+
+// Format implements fmt.Formatter, so that no formatting verb reveals the
+// credential blob or the values of sensitive attributes.
+func (t GenericCredential) Format(f fmt.State, verb rune) {
+	formatCredential(f, verb, &t.Credential, t.GoString)
+}
+
+// String implements fmt.Stringer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t DomainPassword) String() string {
+	return t.Credential.String()
+}
+
+// GoString implements fmt.GoStringer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t DomainPassword) GoString() string {
+	return "wincred.DomainPassword{Credential:" + t.Credential.GoString() + "}"
+}
+
+// Format implements fmt.Formatter, so that no formatting verb reveals the
+// credential blob or the values of sensitive attributes.
+func (t DomainPassword) Format(f fmt.State, verb rune) {
+	formatCredential(f, verb, &t.Credential, t.GoString)
+}
+
+func formatCredential(f fmt.State, verb rune, cred *Credential, goString func() string) {
+	switch verb {
+	case 'v':
+		if f.Flag('#') {
+			fmt.Fprint(f, goString())
+		} else {
+			fmt.Fprint(f, cred.format(f.Flag('+')))
+		}
+	case 's':
+		fmt.Fprint(f, cred.format(false))
+	case 'q':
+		fmt.Fprint(f, strconv.Quote(cred.format(false)))
+	default:
+		fmt.Fprintf(f, "%%!%c(wincred.Credential=%s)", verb, cred.format(false))
+	}
+}
+
+// format returns the credential in the style of the %v and %+v verbs.
+func (t *Credential) format(fieldNames bool) string {
+	attrs := make([]string, len(t.Attributes))
+	for i, attr := range t.Attributes {
+		if fieldNames {
+			attrs[i] = fmt.Sprintf("{Keyword:%s Value:%s}", attr.Keyword, attributeValueString(attr))
+		} else {
+			attrs[i] = fmt.Sprintf("{%s %s}", attr.Keyword, attributeValueString(attr))
+		}
+	}
+	values := []interface{}{
+		t.TargetName,
+		t.Comment,
+		t.LastWritten,
+		redact(t.CredentialBlob),
+		"[" + strings.Join(attrs, " ") + "]",
+		t.TargetAlias,
+		t.UserName,
+		t.Persist,
+		t.Type,
+	}
+	if fieldNames {
+		return fmt.Sprintf("{TargetName:%v Comment:%v LastWritten:%v CredentialBlob:%v Attributes:%v TargetAlias:%v UserName:%v Persist:%v Type:%v}", values...)
+	}
+	return fmt.Sprintf("{%v %v %v %v %v %v %v %v %v}", values...)
+}
+
+// goSyntax returns the credential fields in the style of the %#v verb.
+func (t *Credential) goSyntax() string {
+	attrs := make([]string, len(t.Attributes))
+	for i, attr := range t.Attributes {
+		value := fmt.Sprintf("%#v", attr.Value)
+		if IsSensitiveAttribute(attr.Keyword) {
+			value = strconv.Quote(redact(attr.Value))
+		}
+		attrs[i] = fmt.Sprintf("wincred.CredentialAttribute{Keyword:%q, Value:%s}", attr.Keyword, value)
+	}
+	return fmt.Sprintf(
+		"{TargetName:%q, Comment:%q, LastWritten:%#v, CredentialBlob:%q, Attributes:[]wincred.CredentialAttribute{%s}, TargetAlias:%q, UserName:%q, Persist:%#v, Type:%#v}",
+		t.TargetName,
+		t.Comment,
+		t.LastWritten,
+		redact(t.CredentialBlob),
+		strings.Join(attrs, ", "),
+		t.TargetAlias,
+		t.UserName,
+		t.Persist,
+		t.Type,
+	)
+}
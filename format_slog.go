@@ -0,0 +1,38 @@
+//go:build go1.21
+// +build go1.21
+
+package wincred
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t Credential) LogValue() slog.Value {
+	attrs := make([]slog.Attr, len(t.Attributes))
+	for i, attr := range t.Attributes {
+		attrs[i] = slog.String(attr.Keyword, attributeValueString(attr))
+	}
+	return slog.GroupValue(
+		slog.String("targetName", t.TargetName),
+		slog.String("comment", t.Comment),
+		slog.Time("lastWritten", t.LastWritten),
+		slog.String("credentialBlob", redact(t.CredentialBlob)),
+		slog.Attr{Key: "attributes", Value: slog.GroupValue(attrs...)},
+		slog.String("targetAlias", t.TargetAlias),
+		slog.String("userName", t.UserName),
+		slog.String("persist", t.Persist.String()),
+		slog.String("type", t.Type.String()),
+	)
+}
+
+// LogValue implements slog.LogValuer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t GenericCredential) LogValue() slog.Value {
+	return t.Credential.LogValue()
+}
+
+// LogValue implements slog.LogValuer. The credential blob and the values of
+// sensitive attributes are redacted.
+func (t DomainPassword) LogValue() slog.Value {
+	return t.Credential.LogValue()
+}
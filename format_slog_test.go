@@ -0,0 +1,26 @@
+//go:build go1.21
+// +build go1.21
+
+package wincred
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredential_LogValue(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	cred := fixtureSecretCredential()
+	logger.Info("test", "cred", cred, "generic", GenericCredential{Credential: *cred})
+	out := buf.String()
+	assert.NotContains(t, out, "secret")
+	assert.Contains(t, out, `"targetName":"Foo"`)
+	assert.Contains(t, out, `"credentialBlob":"`+redact([]byte("my secret"))+`"`)
+	assert.Contains(t, out, `"Plain":"[1 2]"`)
+}
@@ -0,0 +1,90 @@
+package wincred
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureSecretCredential() *Credential {
+	return &Credential{
+		TargetName:     "Foo",
+		UserName:       "Nobody",
+		LastWritten:    time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC),
+		CredentialBlob: []byte("my secret"),
+		Attributes: []CredentialAttribute{
+			{Keyword: "Token", Value: []byte("token secret")},
+			{Keyword: "Plain", Value: []byte{1, 2}},
+		},
+		Persist: PersistLocalMachine,
+		Type:    TypeGeneric,
+	}
+}
+
+func TestRedact(t *testing.T) {
+	assert.Equal(t, "[REDACTED len=0]", redact(nil))
+	out := redact([]byte("my secret"))
+	assert.Regexp(t, `^\[REDACTED len=9 hmac=[0-9a-f]{8}\]$`, out)
+	assert.Equal(t, out, redact([]byte("my secret")))
+	assert.NotEqual(t, out, redact([]byte("my secreT")))
+	// An unkeyed hash would allow guessing the secret offline.
+	assert.NotContains(t, out, "b9d1d013")
+}
+
+func TestUnmarkSensitiveAttribute(t *testing.T) {
+	MarkSensitiveAttribute("Token", "Other")
+	assert.True(t, IsSensitiveAttribute("Token"))
+	UnmarkSensitiveAttribute("Token", "Other")
+	assert.False(t, IsSensitiveAttribute("Token"))
+	assert.False(t, IsSensitiveAttribute("Other"))
+	cred := fixtureSecretCredential()
+	assert.Contains(t, cred.String(), "{Token [116 111 107")
+}
+
+func TestCredential_FormatRedacted(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	cred := fixtureSecretCredential()
+	generic := &GenericCredential{Credential: *cred}
+	domain := &DomainPassword{Credential: *cred}
+	verbs := []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"}
+	for _, value := range []interface{}{cred, *cred, generic, *generic, domain, []*Credential{cred}} {
+		for _, verb := range verbs {
+			out := fmt.Sprintf(verb, value)
+			assert.NotContains(t, out, "secret", verb)
+			assert.NotContains(t, out, "[109 121 32", verb)
+			assert.NotContains(t, out, "6d7920736563726574", verb)
+		}
+	}
+	assert.NotContains(t, fmt.Sprint(cred), "secret")
+	assert.NotContains(t, cred.String(), "secret")
+	assert.NotContains(t, cred.GoString(), "secret")
+}
+
+func TestCredential_String(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	cred := fixtureSecretCredential()
+	blob, token := redact(cred.CredentialBlob), redact(cred.Attributes[0].Value)
+	assert.Equal(t,
+		"{Foo  2024-01-02 03:04:05 +0000 UTC "+blob+" [{Token "+token+"} {Plain [1 2]}]  Nobody LocalMachine Generic}",
+		cred.String())
+	assert.Equal(t,
+		"{TargetName:Foo Comment: LastWritten:2024-01-02 03:04:05 +0000 UTC CredentialBlob:"+blob+" Attributes:[{Keyword:Token Value:"+token+"} {Keyword:Plain Value:[1 2]}] TargetAlias: UserName:Nobody Persist:LocalMachine Type:Generic}",
+		fmt.Sprintf("%+v", cred))
+}
+
+func TestCredential_GoString(t *testing.T) {
+	cred := &GenericCredential{Credential: Credential{TargetName: "Foo", CredentialBlob: []byte("my secret")}}
+	out := fmt.Sprintf("%#v", cred)
+	assert.Contains(t, out, "wincred.GenericCredential{Credential:wincred.Credential{TargetName:\"Foo\"")
+	assert.Contains(t, out, "CredentialBlob:"+strconv.Quote(redact([]byte("my secret"))))
+}
+
+func TestCredential_FormatNil(t *testing.T) {
+	var cred *Credential
+	assert.Equal(t, "<nil>", fmt.Sprint(cred))
+}
@@ -0,0 +1,97 @@
+package wincred
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrKeyringNotFound is returned by GoKeyring if the requested secret
+	// does not exist.
+	ErrKeyringNotFound = errors.New("secret not found in keyring")
+
+	// ErrKeyringDataTooBig is returned by GoKeyring.Set if the password or
+	// service name exceed the limits of the credential manager.
+	ErrKeyringDataTooBig = errors.New("data passed to Set was too big")
+)
+
+// GoKeyring provides the API of github.com/zalando/go-keyring. It uses the
+// same layout as go-keyring on Windows, so secrets stored by either library
+// can be read by the other: secrets are generic credentials with the target
+// name "<service>:<user>", the user name as UserName and the UTF-8 encoded
+// password as blob.
+type GoKeyring struct{}
+
+func (GoKeyring) targetName(service, user string) string {
+	return service + ":" + user
+}
+
+// Set stores the password of the user for the given service. Only the byte
+// copy of the password is wiped after writing; the password string itself
+// cannot be wiped and stays in memory until it is garbage collected.
+func (t GoKeyring) Set(service, user, password string) error {
+	// The same limits as go-keyring, the service name limit is empirical.
+	if len(password) > MaxCredentialBlobSize || len(service) >= 512 {
+		return ErrKeyringDataTooBig
+	}
+	cred := NewGenericCredential(t.targetName(service, user))
+	cred.UserName = user
+	cred.CredentialBlob = []byte(password)
+	defer wipeBytes(cred.CredentialBlob)
+	return cred.Write()
+}
+
+// Get returns the password of the user for the given service. The credential
+// is wiped, but the returned string is a copy that cannot be wiped.
+func (t GoKeyring) Get(service, user string) (string, error) {
+	cred, err := GetGenericCredential(t.targetName(service, user))
+	if errors.Is(err, ErrElementNotFound) {
+		return "", ErrKeyringNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	defer cred.Wipe()
+	return string(cred.CredentialBlob), nil
+}
+
+// Delete removes the password of the user for the given service.
+func (t GoKeyring) Delete(service, user string) error {
+	err := NewGenericCredential(t.targetName(service, user)).Delete()
+	if errors.Is(err, ErrElementNotFound) {
+		return ErrKeyringNotFound
+	}
+	return err
+}
+
+// DeleteAll removes the passwords of all users for the given service.
+// It returns ErrKeyringNotFound if there are none, or if the service name is
+// empty, which would otherwise match all secrets.
+func (t GoKeyring) DeleteAll(service string) error {
+	if service == "" {
+		return ErrKeyringNotFound
+	}
+	prefix := t.targetName(service, "")
+	creds, err := listPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	deleted := 0
+	for _, cred := range creds {
+		if cred.Type != TypeGeneric || !strings.HasPrefix(cred.TargetName, prefix) {
+			continue
+		}
+		err := NewGenericCredential(cred.TargetName).Delete()
+		if errors.Is(err, ErrElementNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return ErrKeyringNotFound
+	}
+	return nil
+}
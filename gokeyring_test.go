@@ -0,0 +1,19 @@
+package wincred
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoKeyring_TargetName(t *testing.T) {
+	assert.Equal(t, "my-service:alice", GoKeyring{}.targetName("my-service", "alice"))
+}
+
+func TestGoKeyring_Limits(t *testing.T) {
+	keyring := GoKeyring{}
+	assert.Equal(t, ErrKeyringDataTooBig, keyring.Set("service", "alice", strings.Repeat("x", 2561)))
+	assert.Equal(t, ErrKeyringDataTooBig, keyring.Set(strings.Repeat("s", 512), "alice", "secret"))
+	assert.Equal(t, ErrKeyringNotFound, keyring.DeleteAll(""))
+}
@@ -0,0 +1,195 @@
+package wincred
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyPassword is returned when importing a domain password without
+// secret, as exported by Export, unless ImportOptions.AllowEmptyPasswords is set.
+var ErrEmptyPassword = errors.New("domain password has no secret")
+
+// ConflictPolicy defines how imported credentials are handled if a credential
+// with the same target name and type already exists.
+type ConflictPolicy int
+
+const (
+	// ConflictSkip keeps the existing credential and skips the imported one.
+	ConflictSkip ConflictPolicy = iota
+
+	// ConflictOverwrite replaces the existing credential.
+	ConflictOverwrite
+
+	// ConflictRename stores the imported credential under a new target name
+	// with a numeric suffix, e.g. "target (2)".
+	ConflictRename
+
+	// ConflictNewerWins replaces the existing credential only if the imported
+	// one was written more recently, according to LastWritten.
+	ConflictNewerWins
+)
+
+// ImportAction describes what happened to an imported credential.
+type ImportAction int
+
+const (
+	// ImportCreated indicates that the credential did not exist and was created.
+	ImportCreated ImportAction = iota + 1
+
+	// ImportOverwritten indicates that an existing credential was replaced.
+	ImportOverwritten
+
+	// ImportRenamed indicates that the credential was stored under a new target name.
+	ImportRenamed
+
+	// ImportSkipped indicates that an existing credential was kept.
+	ImportSkipped
+
+	// ImportFailed indicates that the credential could not be stored.
+	ImportFailed
+)
+
+var importActionNames = map[ImportAction]string{
+	ImportCreated:     "created",
+	ImportOverwritten: "overwritten",
+	ImportRenamed:     "renamed",
+	ImportSkipped:     "skipped",
+	ImportFailed:      "failed",
+}
+
+// String returns the name of the import action.
+func (t ImportAction) String() string {
+	if name, ok := importActionNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("ImportAction(%d)", int(t))
+}
+
+// ImportOptions controls how credentials are stored by the import functions.
+type ImportOptions struct {
+	// Conflict defines how existing credentials are handled.
+	Conflict ConflictPolicy
+
+	// DryRun only reports what would be imported without modifying the
+	// credential store.
+	DryRun bool
+
+	// AllowEmptyPasswords imports domain passwords without secret. Otherwise
+	// they fail with ErrEmptyPassword, so that existing passwords are not
+	// replaced by empty ones.
+	AllowEmptyPasswords bool
+}
+
+// ImportResult describes the outcome of importing a single credential.
+type ImportResult struct {
+	// TargetName is the target name of the imported credential.
+	TargetName string
+	// Type is the type of the imported credential.
+	Type CredentialType
+	// Action is what happened to the credential.
+	Action ImportAction
+	// RenamedTo is the new target name if the credential was renamed.
+	RenamedTo string
+	// Err is the error that occurred if the import failed.
+	Err error
+}
+
+// ImportReport lists the outcome of an import.
+type ImportReport struct {
+	Results []ImportResult
+}
+
+// Count returns the number of credentials with the given import action.
+func (t *ImportReport) Count(action ImportAction) int {
+	count := 0
+	for _, result := range t.Results {
+		if result.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// resolveConflict decides what to do with an incoming credential given the
+// already existing one. A nil existing credential means there is no conflict.
+func resolveConflict(policy ConflictPolicy, incoming, existing *Credential) ImportAction {
+	if existing == nil {
+		return ImportCreated
+	}
+	switch policy {
+	case ConflictOverwrite:
+		return ImportOverwritten
+	case ConflictRename:
+		return ImportRenamed
+	case ConflictNewerWins:
+		if incoming.LastWritten.After(existing.LastWritten) {
+			return ImportOverwritten
+		}
+	}
+	return ImportSkipped
+}
+
+// renamedTarget returns the target name with the given numeric suffix.
+func renamedTarget(targetName string, n int) string {
+	return fmt.Sprintf("%s (%d)", targetName, n)
+}
+
+// importCredentials stores the given credentials according to the options.
+// A credential without type is imported as generic credential.
+func importCredentials(creds []*Credential, opts ImportOptions) *ImportReport {
+	report := new(ImportReport)
+	for _, cred := range creds {
+		report.Results = append(report.Results, importCredential(cred.clone(), opts))
+	}
+	return report
+}
+
+// importCredential stores a single credential and wipes it afterwards.
+func importCredential(cred *Credential, opts ImportOptions) ImportResult {
+	defer cred.Wipe()
+	if cred.Type == 0 {
+		cred.Type = TypeGeneric
+	}
+	result := ImportResult{TargetName: cred.TargetName, Type: cred.Type}
+	if cred.Type == TypeDomainPassword && len(cred.CredentialBlob) == 0 && !opts.AllowEmptyPasswords {
+		result.Action, result.Err = ImportFailed, ErrEmptyPassword
+		return result
+	}
+	existing, err := readCredential(cred.TargetName, cred.Type)
+	if err != nil && !errors.Is(err, ErrElementNotFound) {
+		result.Action, result.Err = ImportFailed, err
+		return result
+	}
+	result.Action = resolveConflict(opts.Conflict, cred, existing)
+	if existing != nil {
+		existing.Wipe()
+	}
+	if result.Action == ImportRenamed {
+		if result.RenamedTo, err = freeTargetName(cred.TargetName, cred.Type); err != nil {
+			result.Action, result.Err = ImportFailed, err
+			return result
+		}
+		cred.TargetName = result.RenamedTo
+	}
+	if result.Action != ImportSkipped && !opts.DryRun {
+		if err := writeCredential(cred); err != nil {
+			result.Action, result.Err = ImportFailed, err
+		}
+	}
+	return result
+}
+
+// freeTargetName finds the first numbered variant of the given target name
+// that is not used by a credential of the given type.
+func freeTargetName(targetName string, typ CredentialType) (string, error) {
+	for n := 2; ; n++ {
+		candidate := renamedTarget(targetName, n)
+		_, err := readCredential(candidate, typ)
+		if errors.Is(err, ErrElementNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
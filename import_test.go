@@ -0,0 +1,58 @@
+package wincred
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveConflict(t *testing.T) {
+	older := &Credential{LastWritten: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	newer := &Credential{LastWritten: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		policy   ConflictPolicy
+		incoming *Credential
+		existing *Credential
+		expected ImportAction
+	}{
+		{ConflictSkip, newer, nil, ImportCreated},
+		{ConflictOverwrite, newer, nil, ImportCreated},
+		{ConflictSkip, newer, older, ImportSkipped},
+		{ConflictOverwrite, older, newer, ImportOverwritten},
+		{ConflictRename, newer, older, ImportRenamed},
+		{ConflictNewerWins, newer, older, ImportOverwritten},
+		{ConflictNewerWins, older, newer, ImportSkipped},
+		{ConflictNewerWins, older, older, ImportSkipped},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, resolveConflict(test.policy, test.incoming, test.existing))
+	}
+}
+
+func TestRenamedTarget(t *testing.T) {
+	assert.Equal(t, "Foo (2)", renamedTarget("Foo", 2))
+}
+
+func TestImportAction_String(t *testing.T) {
+	assert.Equal(t, "renamed", ImportRenamed.String())
+	assert.Equal(t, "ImportAction(0)", ImportAction(0).String())
+}
+
+func TestImportReport_Count(t *testing.T) {
+	report := &ImportReport{Results: []ImportResult{
+		{Action: ImportCreated},
+		{Action: ImportSkipped},
+		{Action: ImportCreated},
+	}}
+	assert.Equal(t, 2, report.Count(ImportCreated))
+	assert.Equal(t, 0, report.Count(ImportFailed))
+}
+
+func TestImportCredential_EmptyPassword(t *testing.T) {
+	cred := &Credential{TargetName: "host", Type: TypeDomainPassword, UserName: `corp\admin`}
+	result := importCredential(cred, ImportOptions{Conflict: ConflictOverwrite})
+	assert.Equal(t, ImportFailed, result.Action)
+	assert.True(t, errors.Is(result.Err, ErrEmptyPassword))
+}
@@ -0,0 +1,147 @@
+package wincred
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrKeyNotFound is returned by Keyring if the requested item does not exist.
+var ErrKeyNotFound = errors.New("The specified item could not be found in the keyring")
+
+// AttributeDescription holds the description of items stored by Keyring.
+const AttributeDescription = "Description"
+
+// KeyringItem is an item stored by Keyring, like keyring.Item of
+// github.com/99designs/keyring.
+type KeyringItem struct {
+	Key         string
+	Data        []byte
+	Label       string
+	Description string
+}
+
+// KeyringMetadata is the information about an item that is available without
+// reading its data.
+type KeyringMetadata struct {
+	*KeyringItem
+	ModificationTime time.Time
+}
+
+// KeyringConfig configures a Keyring.
+type KeyringConfig struct {
+	// ServiceName namespaces the items. Defaults to "default".
+	ServiceName string
+	// Prefix is prepended to the target names. Defaults to "keyring".
+	Prefix string
+}
+
+// Keyring provides the Keyring interface of github.com/99designs/keyring.
+// It uses the same target name layout as its wincred backend,
+// "<prefix>:<service>:<key>", so existing items are found. The label of an
+// item is stored as comment and its description as attribute.
+type Keyring struct {
+	prefix string
+}
+
+// NewKeyring returns a keyring with the given configuration.
+func NewKeyring(config KeyringConfig) *Keyring {
+	if config.ServiceName == "" {
+		config.ServiceName = "default"
+	}
+	if config.Prefix == "" {
+		config.Prefix = "keyring"
+	}
+	return &Keyring{prefix: config.Prefix + ":" + config.ServiceName + ":"}
+}
+
+// itemFromCredential converts a credential of the keyring into an item.
+func (t *Keyring) itemFromCredential(cred *Credential, withData bool) *KeyringItem {
+	item := &KeyringItem{Key: strings.TrimPrefix(cred.TargetName, t.prefix), Label: cred.Comment}
+	if description, ok := cred.GetAttribute(AttributeDescription); ok {
+		item.Description = string(description)
+	}
+	if withData {
+		item.Data = append([]byte{}, cred.CredentialBlob...)
+	}
+	return item
+}
+
+// Get returns the item with the given key.
+func (t *Keyring) Get(key string) (KeyringItem, error) {
+	cred, err := GetGenericCredential(t.prefix + key)
+	if errors.Is(err, ErrElementNotFound) {
+		return KeyringItem{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return KeyringItem{}, err
+	}
+	defer cred.Wipe()
+	return *t.itemFromCredential(&cred.Credential, true), nil
+}
+
+// GetMetadata returns the item with the given key without its data, and the
+// time it was last modified.
+func (t *Keyring) GetMetadata(key string) (KeyringMetadata, error) {
+	cred, err := GetGenericCredential(t.prefix + key)
+	if errors.Is(err, ErrElementNotFound) {
+		return KeyringMetadata{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return KeyringMetadata{}, err
+	}
+	defer cred.Wipe()
+	return KeyringMetadata{KeyringItem: t.itemFromCredential(&cred.Credential, false), ModificationTime: cred.LastWritten}, nil
+}
+
+// credentialFromItem converts an item into a credential of the keyring.
+func (t *Keyring) credentialFromItem(item KeyringItem) (*GenericCredential, error) {
+	cred := NewGenericCredential(t.prefix + item.Key)
+	cred.CredentialBlob = item.Data
+	cred.Comment = item.Label
+	if item.Description != "" {
+		if err := cred.SetAttributeString(AttributeDescription, item.Description); err != nil {
+			return nil, err
+		}
+	}
+	return cred, nil
+}
+
+// Set stores the item, replacing an existing item with the same key.
+func (t *Keyring) Set(item KeyringItem) error {
+	cred, err := t.credentialFromItem(item)
+	if err != nil {
+		return err
+	}
+	return cred.Write()
+}
+
+// Remove deletes the item with the given key.
+func (t *Keyring) Remove(key string) error {
+	err := NewGenericCredential(t.prefix + key).Delete()
+	if errors.Is(err, ErrElementNotFound) {
+		return ErrKeyNotFound
+	}
+	return err
+}
+
+// Keys returns the sorted keys of all items of the keyring.
+func (t *Keyring) Keys() ([]string, error) {
+	creds, err := listPrefix(t.prefix)
+	if err != nil {
+		return nil, err
+	}
+	return t.keys(creds), nil
+}
+
+func (t *Keyring) keys(creds []*Credential) []string {
+	keys := []string{}
+	for _, cred := range creds {
+		if cred.Type == TypeGeneric && strings.HasPrefix(cred.TargetName, t.prefix) {
+			keys = append(keys, strings.TrimPrefix(cred.TargetName, t.prefix))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
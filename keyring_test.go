@@ -0,0 +1,39 @@
+package wincred
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring_Layout(t *testing.T) {
+	assert.Equal(t, "keyring:default:", NewKeyring(KeyringConfig{}).prefix)
+	assert.Equal(t, "corp:aws-vault:", NewKeyring(KeyringConfig{ServiceName: "aws-vault", Prefix: "corp"}).prefix)
+}
+
+func TestKeyring_ItemConversion(t *testing.T) {
+	keyring := NewKeyring(KeyringConfig{ServiceName: "svc"})
+	item := KeyringItem{Key: "token", Data: []byte("secret"), Label: "API token", Description: "used by CI"}
+	cred, err := keyring.credentialFromItem(item)
+	assert.Nil(t, err)
+	assert.Equal(t, "keyring:svc:token", cred.TargetName)
+	assert.Equal(t, "API token", cred.Comment)
+	assert.Equal(t, []byte("secret"), cred.CredentialBlob)
+
+	assert.Equal(t, &item, keyring.itemFromCredential(&cred.Credential, true))
+	cred.LastWritten = time.Now()
+	withoutData := keyring.itemFromCredential(&cred.Credential, false)
+	assert.Nil(t, withoutData.Data)
+	assert.Equal(t, "used by CI", withoutData.Description)
+}
+
+func TestKeyring_Keys(t *testing.T) {
+	keyring := NewKeyring(KeyringConfig{ServiceName: "svc"})
+	creds := []*Credential{
+		{TargetName: "keyring:svc:b", Type: TypeGeneric},
+		{TargetName: "keyring:svc:a", Type: TypeGeneric},
+		{TargetName: "keyring:other:c", Type: TypeGeneric},
+	}
+	assert.Equal(t, []string{"a", "b"}, keyring.keys(creds))
+}
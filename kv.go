@@ -0,0 +1,139 @@
+package wincred
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KV is a simple key-value store on top of generic credentials. Keys are
+// stored as target names "<prefix>:<key>" and values as UTF-8 encoded blobs.
+type KV struct {
+	// Prefix namespaces the keys, usually the name of the application.
+	Prefix string
+	// Persist is the persistence of newly written values.
+	Persist CredentialPersistence
+}
+
+// NewKV returns a key-value store for the given prefix. Values are
+// persisted local-machine-wide.
+func NewKV(prefix string) *KV {
+	return &KV{Prefix: prefix, Persist: PersistLocalMachine}
+}
+
+// escapeKey escapes the characters of a key that have a special meaning in
+// target names or enumeration filters, as well as control characters.
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c == '%' || c == '*' || c < 0x20 || c == 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unescapeKey reverses escapeKey.
+func unescapeKey(escaped string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '%' {
+			b.WriteByte(escaped[i])
+			continue
+		}
+		if i+2 >= len(escaped) {
+			return "", fmt.Errorf("%w: bad escape in %q", ErrInvalidEncoding, escaped)
+		}
+		c, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("%w: bad escape in %q", ErrInvalidEncoding, escaped)
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// target returns the target name of the given key.
+func (t *KV) target(key string) string {
+	return t.Prefix + ":" + escapeKey(key)
+}
+
+// Set stores the value under the given key. Only the byte copy of the value
+// is wiped after writing; Go strings are immutable, so the value itself stays
+// in memory until it is garbage collected.
+func (t *KV) Set(key, value string) error {
+	cred := NewGenericCredential(t.target(key))
+	cred.Persist = t.Persist
+	cred.CredentialBlob = []byte(value)
+	defer wipeBytes(cred.CredentialBlob)
+	return cred.Write()
+}
+
+// Lookup returns the value stored under the given key and whether it exists.
+// The credential is wiped, but the returned string is a copy that cannot be
+// wiped.
+func (t *KV) Lookup(key string) (string, bool, error) {
+	cred, err := GetGenericCredential(t.target(key))
+	if errors.Is(err, ErrElementNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	defer cred.Wipe()
+	return string(cred.CredentialBlob), true, nil
+}
+
+// Get returns the value stored under the given key. It returns
+// ErrElementNotFound if the key does not exist.
+func (t *KV) Get(key string) (string, error) {
+	value, ok, err := t.Lookup(key)
+	if err == nil && !ok {
+		err = ErrElementNotFound
+	}
+	return value, err
+}
+
+// Delete removes the given key and reports whether it existed.
+func (t *KV) Delete(key string) (bool, error) {
+	err := NewGenericCredential(t.target(key)).Delete()
+	if errors.Is(err, ErrElementNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Keys returns the sorted keys starting with the given prefix.
+func (t *KV) Keys(prefix string) ([]string, error) {
+	creds, err := listPrefix(t.target(prefix))
+	if err != nil {
+		return nil, err
+	}
+	defer wipeCredentials(creds)
+	return t.keys(creds)
+}
+
+// keys returns the sorted keys of the given credentials that belong to the
+// store. The prefix of the store is compared case-insensitively, like Windows
+// compares target names.
+func (t *KV) keys(creds []*Credential) ([]string, error) {
+	keys := []string{}
+	for _, cred := range creds {
+		if cred.Type != TypeGeneric || !hasPrefixFold(cred.TargetName, t.Prefix+":") {
+			continue
+		}
+		key, err := unescapeKey(cred.TargetName[len(t.Prefix)+1:])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
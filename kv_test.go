@@ -0,0 +1,58 @@
+package wincred
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeKey(t *testing.T) {
+	assert.Equal(t, "a/b:c", escapeKey("a/b:c"))
+	assert.Equal(t, "100%25%2A%0A", escapeKey("100%*\n"))
+	for _, key := range []string{"", "plain", "100%*\n", "grüße"} {
+		unescaped, err := unescapeKey(escapeKey(key))
+		assert.Nil(t, err)
+		assert.Equal(t, key, unescaped)
+	}
+	_, err := unescapeKey("bad%2")
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+	_, err = unescapeKey("bad%zz")
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+}
+
+func TestKV_Keys(t *testing.T) {
+	kv := NewKV("myapp")
+	assert.Equal(t, "myapp:a%2Ab", kv.target("a*b"))
+	creds := []*Credential{
+		{TargetName: "myapp:z", Type: TypeGeneric},
+		{TargetName: "myapp:a%2Ab", Type: TypeGeneric},
+		{TargetName: "myapp:domain", Type: TypeDomainPassword},
+		{TargetName: "myapp2:x", Type: TypeGeneric},
+	}
+	keys, err := kv.keys(creds)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a*b", "z"}, keys)
+}
+
+func TestKV_KeysMixedCase(t *testing.T) {
+	kv := NewKV("myapp")
+	creds := []*Credential{
+		{TargetName: "MyApp:upper", Type: TypeGeneric},
+		{TargetName: "myapp:lower", Type: TypeGeneric},
+	}
+	keys, err := kv.keys(creds)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"lower", "upper"}, keys)
+}
+
+func TestKV_KeysWildcardPrefix(t *testing.T) {
+	kv := NewKV("my*app")
+	creds := []*Credential{
+		{TargetName: "my*app:key", Type: TypeGeneric},
+		{TargetName: "my-other-app:key", Type: TypeGeneric},
+	}
+	keys, err := kv.keys(creds)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+}
@@ -0,0 +1,208 @@
+package wincred
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// LookupRule describes how a credential found by Lookup matched the URL.
+type LookupRule int
+
+const (
+	// LookupPath indicates that the target name matched the complete URL,
+	// including its path.
+	LookupPath LookupRule = iota + 1
+
+	// LookupHostPort indicates that the target name matched the host and the
+	// non-default port of the URL.
+	LookupHostPort
+
+	// LookupHost indicates that the target name matched the host of the URL.
+	LookupHost
+
+	// LookupWildcard indicates that the target name is a wildcard domain, like
+	// "*.example.com", that matched the host of the URL.
+	LookupWildcard
+)
+
+var lookupRuleNames = map[LookupRule]string{
+	LookupPath:     "path",
+	LookupHostPort: "host+port",
+	LookupHost:     "host",
+	LookupWildcard: "wildcard",
+}
+
+// String returns the name of the lookup rule.
+func (t LookupRule) String() string {
+	if name, ok := lookupRuleNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("LookupRule(%d)", int(t))
+}
+
+// ErrInvalidURL is returned by Lookup for URLs without scheme or host.
+var ErrInvalidURL = errors.New("invalid url")
+
+// lookupURL is the normalised form of a URL used for matching target names.
+type lookupURL struct {
+	Protocol string
+	User     string
+	Host     string
+	Port     int
+	Path     string
+}
+
+var defaultPorts = map[string]int{"http": 80, "https": 443, "ftp": 21, "ssh": 22}
+
+// parseLookupURL normalises the given URL: scheme and host are lower-cased,
+// default ports and trailing slashes are removed.
+func parseLookupURL(rawURL string) (lookupURL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return lookupURL{}, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return lookupURL{}, fmt.Errorf("%w: %q", ErrInvalidURL, rawURL)
+	}
+	result := lookupURL{Protocol: strings.ToLower(u.Scheme), Host: strings.ToLower(u.Hostname())}
+	if u.User != nil {
+		result.User = u.User.Username()
+	}
+	if port := u.Port(); port != "" {
+		if result.Port, err = strconv.Atoi(port); err != nil {
+			return lookupURL{}, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+	}
+	result.Port = normalisePort(result.Protocol, result.Port)
+	result.Path = strings.TrimRight(u.Path, "/")
+	return result, nil
+}
+
+func normalisePort(protocol string, port int) int {
+	if port == defaultPorts[strings.ToLower(protocol)] {
+		return 0
+	}
+	return port
+}
+
+// matchTarget reports whether the given target name matches the URL and
+// which rule applies. A target name with a path only matches URLs with the
+// same path, like git's credential.useHttpPath.
+func (t lookupURL) matchTarget(targetName string) (LookupRule, bool) {
+	name := ParseTargetName(targetName)
+	switch name.Scheme {
+	case SchemeURL, SchemeGit:
+		if !strings.EqualFold(name.Protocol, t.Protocol) {
+			return 0, false
+		}
+		if name.User != "" && name.User != t.User {
+			return 0, false
+		}
+		name.Port = normalisePort(name.Protocol, name.Port)
+	case "":
+		name.Host, name.Port = splitHostPort(name.Target)
+		if strings.ContainsAny(name.Host, "/*") {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if !strings.EqualFold(name.Host, t.Host) || name.Port != t.Port {
+		return 0, false
+	}
+	switch path := strings.TrimRight(name.Path, "/"); {
+	case path != "":
+		return LookupPath, path == t.Path
+	case name.Port != 0:
+		return LookupHostPort, true
+	}
+	return LookupHost, true
+}
+
+// wildcardTargets returns the wildcard domain target names matching the
+// host of the URL, the most specific one first.
+func (t lookupURL) wildcardTargets() []string {
+	var targets []string
+	labels := strings.Split(t.Host, ".")
+	for i := 1; i < len(labels); i++ {
+		targets = append(targets, "*."+strings.Join(labels[i:], "."))
+	}
+	return targets
+}
+
+// filters returns the FilteredList filters that cover all target names
+// that can match the URL.
+func (t lookupURL) filters() []string {
+	base := t.Protocol + "://"
+	if t.User != "" {
+		base += t.User + "@"
+	}
+	base += t.Host
+	filters := []string{base + "*", gitTargetPrefix + base + "*", t.Host + "*"}
+	if t.User != "" {
+		plain := t.Protocol + "://" + t.Host + "*"
+		filters = append(filters, plain, gitTargetPrefix+plain)
+	}
+	return filters
+}
+
+// bestMatch returns the generic credential that matches the URL best.
+// Ties are resolved in favour of the first credential.
+func (t lookupURL) bestMatch(creds []*Credential) (*Credential, LookupRule) {
+	var best *Credential
+	var bestRule LookupRule
+	for _, cred := range creds {
+		if cred.Type != TypeGeneric {
+			continue
+		}
+		rule, ok := t.matchTarget(cred.TargetName)
+		if ok && (best == nil || rule < bestRule) {
+			best, bestRule = cred, rule
+		}
+	}
+	return best, bestRule
+}
+
+// Lookup finds the generic credential that matches the given URL best.
+// Target names are compared against the URL in the forms "https://host/path",
+// "git:https://host/path" and "host", in this order of preference:
+// the complete URL including its path, host and port, host, and finally
+// wildcard domains like "*.example.com".
+// Credentials with a path only match URLs with the same path.
+// ErrElementNotFound is returned if no credential matches.
+func Lookup(rawURL string) (*GenericCredential, LookupRule, error) {
+	u, err := parseLookupURL(rawURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	var creds []*Credential
+	seen := make(map[string]bool)
+	for _, filter := range u.filters() {
+		list, err := FilteredList(filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, cred := range list {
+			if key := strings.ToLower(cred.TargetName); cred.Type == TypeGeneric && !seen[key] {
+				seen[key] = true
+				creds = append(creds, cred)
+			}
+		}
+	}
+	if best, rule := u.bestMatch(creds); best != nil {
+		return &GenericCredential{Credential: *best}, rule, nil
+	}
+	for _, target := range u.wildcardTargets() {
+		cred, err := GetGenericCredential(target)
+		if err == nil {
+			return cred, LookupWildcard, nil
+		}
+		if !errors.Is(err, ErrElementNotFound) {
+			return nil, 0, err
+		}
+	}
+	return nil, 0, ErrElementNotFound
+}
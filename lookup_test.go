@@ -0,0 +1,55 @@
+package wincred
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLookupURL(t *testing.T) {
+	u, err := parseLookupURL("HTTPS://bob@Git.Corp.Example:443/team/repo.git/")
+	assert.Nil(t, err)
+	assert.Equal(t, lookupURL{Protocol: "https", User: "bob", Host: "git.corp.example", Path: "/team/repo.git"}, u)
+
+	_, err = parseLookupURL("git.corp.example")
+	assert.True(t, errors.Is(err, ErrInvalidURL))
+}
+
+func TestLookupURL_BestMatch(t *testing.T) {
+	u, _ := parseLookupURL("https://git.corp.example/team/repo.git")
+	creds := []*Credential{
+		{TargetName: "git.corp.example", Type: TypeGeneric},
+		{TargetName: "git:https://git.corp.example/other/repo.git", Type: TypeGeneric},
+		{TargetName: "http://git.corp.example/team/repo.git", Type: TypeGeneric},
+		{TargetName: "git:https://git.corp.example/team/repo.git", Type: TypeGeneric},
+		{TargetName: "git.corp.example", Type: TypeDomainPassword},
+	}
+	best, rule := u.bestMatch(creds)
+	assert.Equal(t, creds[3], best)
+	assert.Equal(t, LookupPath, rule)
+
+	best, rule = u.bestMatch(creds[:3])
+	assert.Equal(t, creds[0], best)
+	assert.Equal(t, LookupHost, rule)
+
+	best, _ = u.bestMatch(creds[1:3])
+	assert.Nil(t, best)
+}
+
+func TestLookupURL_HostPort(t *testing.T) {
+	u, _ := parseLookupURL("https://git.corp.example:8443/team")
+	creds := []*Credential{
+		{TargetName: "https://git.corp.example", Type: TypeGeneric},
+		{TargetName: "git.corp.example:8443", Type: TypeGeneric},
+	}
+	best, rule := u.bestMatch(creds)
+	assert.Equal(t, creds[1], best)
+	assert.Equal(t, LookupHostPort, rule)
+	assert.Equal(t, "host+port", rule.String())
+}
+
+func TestLookupURL_WildcardTargets(t *testing.T) {
+	u, _ := parseLookupURL("https://git.corp.example")
+	assert.Equal(t, []string{"*.corp.example", "*.example"}, u.wildcardTargets())
+}
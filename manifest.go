@@ -0,0 +1,486 @@
+package wincred
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrInvalidManifest is returned for manifests that cannot be planned.
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// Defaults of generated secrets.
+const (
+	DefaultGeneratedLength  = 32
+	DefaultGeneratedCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// Manifest describes the desired state of a set of credentials. Manifests are
+// read from JSON with ReadManifest. Reading YAML is out of scope, as the
+// package does not depend on a YAML library; the yaml struct tags allow
+// applications to decode YAML manifests with gopkg.in/yaml.v3 themselves.
+type Manifest struct {
+	// Prefix is the target name prefix owned by the manifest. When pruning,
+	// credentials below it that are not listed in the manifest are deleted.
+	Prefix      string               `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Credentials []ManifestCredential `json:"credentials" yaml:"credentials"`
+}
+
+// ManifestCredential describes a desired credential.
+type ManifestCredential struct {
+	TargetName string `json:"targetName" yaml:"targetName"`
+	// Type defaults to TypeGeneric.
+	Type     CredentialType `json:"type,omitempty" yaml:"type,omitempty"`
+	UserName string         `json:"userName,omitempty" yaml:"userName,omitempty"`
+	Comment  string         `json:"comment,omitempty" yaml:"comment,omitempty"`
+	// Persist defaults to PersistLocalMachine.
+	Persist    CredentialPersistence `json:"persist,omitempty" yaml:"persist,omitempty"`
+	Attributes map[string]string     `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	Secret     SecretSource          `json:"secret,omitempty" yaml:"secret,omitempty"`
+}
+
+// SecretSource defines where the secret of a credential comes from. At most
+// one of the sources may be set; without source the credential has an empty
+// secret.
+type SecretSource struct {
+	// Env is the name of the environment variable holding the secret.
+	Env string `json:"env,omitempty" yaml:"env,omitempty"`
+	// File is the path of the file holding the secret. Trailing line breaks
+	// are removed.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Generate creates a random secret when the credential is created.
+	// Secrets of existing credentials are kept. Windows does not reveal the
+	// secrets of domain passwords though, so updating a domain password
+	// generates a new secret, which the plan lists as CredentialBlob change.
+	// For the same reason, domain passwords with secrets from Env or File are
+	// updated whenever the manifest is applied.
+	Generate *SecretGenerator `json:"generate,omitempty" yaml:"generate,omitempty"`
+	// Encoding is the encoding of the secret in the credential blob, "utf-8"
+	// (the default) or "utf-16le". Domain passwords are always encoded as
+	// UTF-16LE, as Windows expects.
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+// SecretGenerator defines how random secrets are generated.
+type SecretGenerator struct {
+	// Length is the number of characters, DefaultGeneratedLength if zero.
+	Length int `json:"length,omitempty" yaml:"length,omitempty"`
+	// Charset holds the allowed characters, DefaultGeneratedCharset if empty.
+	Charset string `json:"charset,omitempty" yaml:"charset,omitempty"`
+}
+
+// ReadManifest decodes a JSON manifest. Unknown fields are rejected.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	manifest := new(Manifest)
+	if err := decoder.Decode(manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	return manifest, nil
+}
+
+// PlanAction describes what applying a manifest does to a credential.
+type PlanAction int
+
+const (
+	// PlanCreate indicates that the credential does not exist yet.
+	PlanCreate PlanAction = iota + 1
+
+	// PlanUpdate indicates that the credential differs from the manifest.
+	PlanUpdate
+
+	// PlanDelete indicates that the credential is not listed in the manifest
+	// and is pruned.
+	PlanDelete
+
+	// PlanNoop indicates that the credential matches the manifest.
+	PlanNoop
+)
+
+var planActionNames = map[PlanAction]string{
+	PlanCreate: "create",
+	PlanUpdate: "update",
+	PlanDelete: "delete",
+	PlanNoop:   "noop",
+}
+
+// String returns the name of the action.
+func (t PlanAction) String() string {
+	if name, ok := planActionNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("PlanAction(%d)", int(t))
+}
+
+// PlanChange describes the planned change of a single credential. It never
+// contains secrets.
+type PlanChange struct {
+	TargetName string
+	Type       CredentialType
+	Action     PlanAction
+	// Changes lists the names of the differing fields of updated credentials.
+	Changes []string
+}
+
+// String returns a one-line description of the change.
+func (t PlanChange) String() string {
+	if len(t.Changes) == 0 {
+		return fmt.Sprintf("%s %s %s", t.Action, t.Type, t.TargetName)
+	}
+	return fmt.Sprintf("%s %s %s (%s)", t.Action, t.Type, t.TargetName, strings.Join(t.Changes, ", "))
+}
+
+// ManifestPlan lists the changes needed to converge the credential store to
+// a manifest, in the order they are applied.
+type ManifestPlan struct {
+	Changes []PlanChange
+}
+
+// Count returns the number of credentials with the given action.
+func (t *ManifestPlan) Count(action PlanAction) int {
+	count := 0
+	for _, change := range t.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// ManifestOptions controls planning and applying manifests.
+type ManifestOptions struct {
+	// Prune deletes credentials below the prefix of the manifest that are not
+	// listed in it. It requires a non-empty prefix.
+	Prune bool
+}
+
+// manifestStep is a planned change with the desired credential, which is nil
+// for deletions.
+type manifestStep struct {
+	change PlanChange
+	cred   *Credential
+}
+
+func wipeSteps(steps []manifestStep) {
+	for _, step := range steps {
+		if step.cred != nil {
+			step.cred.Wipe()
+		}
+	}
+}
+
+// generateSecret returns a random secret.
+func generateSecret(gen *SecretGenerator) ([]byte, error) {
+	length, charset := gen.Length, []rune(gen.Charset)
+	if length == 0 {
+		length = DefaultGeneratedLength
+	}
+	if len(charset) == 0 {
+		charset = []rune(DefaultGeneratedCharset)
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("%w: negative secret length", ErrInvalidManifest)
+	}
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return nil, err
+		}
+		b.WriteRune(charset[n.Int64()])
+	}
+	return []byte(b.String()), nil
+}
+
+// resolve returns the secret of the source, or nil if it has none.
+func (t SecretSource) resolve() ([]byte, error) {
+	switch {
+	case t.Env != "":
+		value, ok := os.LookupEnv(t.Env)
+		if !ok {
+			return nil, fmt.Errorf("%w: environment variable %s is not set", ErrInvalidManifest, t.Env)
+		}
+		return []byte(value), nil
+	case t.File != "":
+		data, err := os.ReadFile(t.File)
+		if err != nil {
+			return nil, err
+		}
+		secret := append([]byte{}, bytes.TrimRight(data, "\r\n")...)
+		wipeBytes(data)
+		return secret, nil
+	case t.Generate != nil:
+		return generateSecret(t.Generate)
+	}
+	return nil, nil
+}
+
+// validate checks that at most one source is set and returns the encoding of
+// the secret for credentials of the given type.
+func (t SecretSource) validate(typ CredentialType) (string, error) {
+	sources := 0
+	for _, set := range []bool{t.Env != "", t.File != "", t.Generate != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", fmt.Errorf("%w: more than one secret source", ErrInvalidManifest)
+	}
+	encoding := t.Encoding
+	if encoding == "" {
+		encoding = encodingUTF8
+		if typ == TypeDomainPassword {
+			encoding = encodingUTF16LE
+		}
+	}
+	switch {
+	case encoding != encodingUTF8 && encoding != encodingUTF16LE:
+		return "", fmt.Errorf("%w: unknown secret encoding %q", ErrInvalidManifest, encoding)
+	case typ == TypeDomainPassword && encoding != encodingUTF16LE:
+		return "", fmt.Errorf("%w: domain passwords must be encoded as %s", ErrInvalidManifest, encodingUTF16LE)
+	}
+	return encoding, nil
+}
+
+// utf8ToUTF16LE encodes the given UTF-8 text as UTF-16 little-endian without
+// creating intermediate strings, so that secrets can be wiped.
+func utf8ToUTF16LE(b []byte) []byte {
+	result := make([]byte, 0, len(b)*2)
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			result = append(result, byte(r1), byte(r1>>8), byte(r2), byte(r2>>8))
+			continue
+		}
+		result = append(result, byte(r), byte(r>>8))
+	}
+	return result
+}
+
+// credential returns the desired credential. The existing credential is nil
+// if there is none.
+func (t *ManifestCredential) credential(existing *Credential) (*Credential, error) {
+	cred := &Credential{
+		TargetName: t.TargetName,
+		Type:       t.Type,
+		UserName:   t.UserName,
+		Comment:    t.Comment,
+		Persist:    t.Persist,
+	}
+	if cred.Type == 0 {
+		cred.Type = TypeGeneric
+	}
+	if cred.Type != TypeGeneric && cred.Type != TypeDomainPassword {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, cred.Type)
+	}
+	if cred.Persist == 0 {
+		cred.Persist = PersistLocalMachine
+	}
+	keywords := make([]string, 0, len(t.Attributes))
+	for keyword := range t.Attributes {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		if err := cred.SetAttributeString(keyword, t.Attributes[keyword]); err != nil {
+			return nil, err
+		}
+	}
+	encoding, err := t.Secret.validate(cred.Type)
+	if err != nil {
+		return nil, err
+	}
+	if t.Secret.Generate != nil && existing != nil && len(existing.CredentialBlob) > 0 {
+		cred.CredentialBlob = append([]byte{}, existing.CredentialBlob...)
+		return cred, nil
+	}
+	secret, err := t.Secret.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if encoding == encodingUTF16LE {
+		cred.CredentialBlob = utf8ToUTF16LE(secret)
+		wipeBytes(secret)
+	} else {
+		cred.CredentialBlob = secret
+	}
+	return cred, nil
+}
+
+// manifestChanges returns the names of the fields that differ between the
+// existing credential and the desired credential of the manifest entry.
+func manifestChanges(entry *ManifestCredential, old, cred *Credential) []string {
+	if cred.Type != TypeDomainPassword || len(old.CredentialBlob) > 0 {
+		return credentialChanges(old, cred)
+	}
+	// Windows does not reveal the secrets of domain passwords, so only the
+	// other fields can be compared.
+	masked := *old
+	masked.CredentialBlob = cred.CredentialBlob
+	changes := credentialChanges(&masked, cred)
+	switch {
+	case entry.Secret.Env != "" || entry.Secret.File != "":
+		// The secret may have changed, so it is always written.
+		return credentialChanges(old, cred)
+	case entry.Secret.Generate != nil && len(changes) > 0:
+		// Updates write a newly generated secret.
+		return credentialChanges(old, cred)
+	default:
+		return changes
+	}
+}
+
+// planManifest computes the steps converging the given current credentials
+// to the manifest. The current credentials must include the credentials
+// below the prefix of the manifest and those listed in it. Domain password
+// secrets cannot be read back, so domain passwords with secrets from an
+// environment variable or a file are always updated.
+func planManifest(m *Manifest, current []*Credential, opts ManifestOptions) ([]manifestStep, error) {
+	if opts.Prune && m.Prefix == "" {
+		return nil, fmt.Errorf("%w: pruning requires a prefix", ErrInvalidManifest)
+	}
+	// Windows compares target names case-insensitively
+	existing := make(map[environmentKey]*Credential)
+	for _, cred := range current {
+		existing[environmentKey{strings.ToLower(cred.TargetName), cred.Type}] = cred
+	}
+	var steps []manifestStep
+	listed := make(map[environmentKey]bool)
+	for i := range m.Credentials {
+		entry := &m.Credentials[i]
+		if entry.TargetName == "" {
+			wipeSteps(steps)
+			return nil, fmt.Errorf("%w: credential %d has no target name", ErrInvalidManifest, i)
+		}
+		typ := entry.Type
+		if typ == 0 {
+			typ = TypeGeneric
+		}
+		key := environmentKey{strings.ToLower(entry.TargetName), typ}
+		if listed[key] {
+			wipeSteps(steps)
+			return nil, fmt.Errorf("%w: duplicate %v credential %q", ErrInvalidManifest, typ, entry.TargetName)
+		}
+		listed[key] = true
+		old := existing[key]
+		cred, err := entry.credential(old)
+		if err != nil {
+			wipeSteps(steps)
+			return nil, fmt.Errorf("%s: %w", entry.TargetName, err)
+		}
+		step := manifestStep{change: PlanChange{TargetName: cred.TargetName, Type: cred.Type, Action: PlanCreate}, cred: cred}
+		if old != nil {
+			step.change.Changes = manifestChanges(entry, old, cred)
+			step.change.Action = PlanNoop
+			if len(step.change.Changes) > 0 {
+				step.change.Action = PlanUpdate
+			}
+		}
+		steps = append(steps, step)
+	}
+	if opts.Prune {
+		var deletions []manifestStep
+		for key, cred := range existing {
+			if hasPrefixFold(cred.TargetName, m.Prefix) && !listed[key] {
+				deletions = append(deletions, manifestStep{change: PlanChange{TargetName: cred.TargetName, Type: cred.Type, Action: PlanDelete}})
+			}
+		}
+		sort.Slice(deletions, func(i, j int) bool {
+			if deletions[i].change.TargetName != deletions[j].change.TargetName {
+				return deletions[i].change.TargetName < deletions[j].change.TargetName
+			}
+			return deletions[i].change.Type < deletions[j].change.Type
+		})
+		steps = append(steps, deletions...)
+	}
+	return steps, nil
+}
+
+// manifestSteps reads the current state of the credentials of the manifest
+// and plans the steps converging it.
+func manifestSteps(m *Manifest, opts ManifestOptions) ([]manifestStep, error) {
+	var current []*Credential
+	if m.Prefix != "" {
+		var err error
+		if current, err = listPrefix(m.Prefix); err != nil {
+			return nil, err
+		}
+	}
+	defer func() { wipeCredentials(current) }()
+	for _, entry := range m.Credentials {
+		if m.Prefix != "" && hasPrefixFold(entry.TargetName, m.Prefix) {
+			continue
+		}
+		typ := entry.Type
+		if typ == 0 {
+			typ = TypeGeneric
+		}
+		cred, err := readCredential(entry.TargetName, typ)
+		if errors.Is(err, ErrElementNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		current = append(current, cred)
+	}
+	return planManifest(m, current, opts)
+}
+
+func planFromSteps(steps []manifestStep) *ManifestPlan {
+	plan := new(ManifestPlan)
+	for _, step := range steps {
+		plan.Changes = append(plan.Changes, step.change)
+	}
+	return plan
+}
+
+// PlanManifest returns the changes needed to converge the credential store to
+// the manifest without modifying it.
+func PlanManifest(m *Manifest, opts ManifestOptions) (*ManifestPlan, error) {
+	steps, err := manifestSteps(m, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeSteps(steps)
+	return planFromSteps(steps), nil
+}
+
+// ApplyManifest converges the credential store to the manifest and returns
+// the executed plan. Credentials are created and updated in the order of the
+// manifest before pruned credentials are deleted. Applying stops at the first
+// error; the changes before it have been applied.
+func ApplyManifest(m *Manifest, opts ManifestOptions) (*ManifestPlan, error) {
+	steps, err := manifestSteps(m, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeSteps(steps)
+	for _, step := range steps {
+		switch step.change.Action {
+		case PlanCreate, PlanUpdate:
+			err = writeCredential(step.cred)
+		case PlanDelete:
+			err = deleteCredential(&Credential{TargetName: step.change.TargetName, Type: step.change.Type})
+			if errors.Is(err, ErrElementNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return planFromSteps(steps), fmt.Errorf("%s %s: %w", step.change.Action, step.change.TargetName, err)
+		}
+	}
+	return planFromSteps(steps), nil
+}
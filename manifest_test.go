@@ -0,0 +1,128 @@
+package wincred
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadManifest(t *testing.T) {
+	m, err := ReadManifest(strings.NewReader(`{
+		"prefix": "app/",
+		"credentials": [{
+			"targetName": "app/db",
+			"type": "Generic",
+			"userName": "admin",
+			"persist": "Enterprise",
+			"attributes": {"host": "db"},
+			"secret": {"generate": {"length": 16}}
+		}]
+	}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &Manifest{Prefix: "app/", Credentials: []ManifestCredential{{
+		TargetName: "app/db",
+		Type:       TypeGeneric,
+		UserName:   "admin",
+		Persist:    PersistEnterprise,
+		Attributes: map[string]string{"host": "db"},
+		Secret:     SecretSource{Generate: &SecretGenerator{Length: 16}},
+	}}}, m)
+
+	_, err = ReadManifest(strings.NewReader(`{"credentials": [{"target": "x"}]}`))
+	assert.True(t, errors.Is(err, ErrInvalidManifest))
+}
+
+func TestPlanManifest(t *testing.T) {
+	t.Setenv("WINCRED_TEST_SECRET", "env-secret")
+	file := filepath.Join(t.TempDir(), "secret")
+	assert.Nil(t, os.WriteFile(file, []byte("file-secret\r\n"), 0o600))
+
+	m := &Manifest{Prefix: "app/", Credentials: []ManifestCredential{
+		{TargetName: "app/new", Secret: SecretSource{Env: "WINCRED_TEST_SECRET"}},
+		{TargetName: "app/same", UserName: "admin", Secret: SecretSource{File: file}},
+		{TargetName: "app/changed", Attributes: map[string]string{"host": "db"}, Secret: SecretSource{Env: "WINCRED_TEST_SECRET"}},
+		{TargetName: "app/generated", Secret: SecretSource{Generate: &SecretGenerator{}}},
+		{TargetName: "App/Wide", Secret: SecretSource{Env: "WINCRED_TEST_SECRET", Encoding: "utf-16le"}},
+		{TargetName: "other", Type: TypeDomainPassword, UserName: `corp\admin`, Secret: SecretSource{Env: "WINCRED_TEST_SECRET"}},
+		{TargetName: "rotated", Type: TypeDomainPassword, UserName: `corp\admin`, Comment: "new", Secret: SecretSource{Generate: &SecretGenerator{}}},
+		{TargetName: "kept", Type: TypeDomainPassword, UserName: `corp\admin`, Secret: SecretSource{Generate: &SecretGenerator{}}},
+		{TargetName: "empty", Type: TypeDomainPassword, UserName: `corp\admin`},
+	}}
+	current := []*Credential{
+		{TargetName: "app/same", Type: TypeGeneric, UserName: "admin", Persist: PersistLocalMachine, CredentialBlob: []byte("file-secret")},
+		{TargetName: "app/changed", Type: TypeGeneric, Persist: PersistSession, CredentialBlob: []byte("old-secret")},
+		{TargetName: "app/generated", Type: TypeGeneric, Persist: PersistLocalMachine, CredentialBlob: []byte("kept")},
+		{TargetName: "app/stale", Type: TypeGeneric},
+		{TargetName: "app/wide", Type: TypeGeneric, Persist: PersistLocalMachine, CredentialBlob: encodeUTF16LE("env-secret")},
+		{TargetName: "other", Type: TypeDomainPassword, UserName: `corp\admin`, Persist: PersistLocalMachine},
+		{TargetName: "rotated", Type: TypeDomainPassword, UserName: `corp\admin`, Persist: PersistLocalMachine},
+		{TargetName: "kept", Type: TypeDomainPassword, UserName: `corp\admin`, Persist: PersistLocalMachine},
+		{TargetName: "empty", Type: TypeDomainPassword, UserName: `corp\admin`, Persist: PersistLocalMachine},
+		{TargetName: "unowned", Type: TypeGeneric},
+	}
+
+	steps, err := planManifest(m, current, ManifestOptions{Prune: true})
+	assert.Nil(t, err)
+	plan := planFromSteps(steps)
+	assert.Equal(t, []PlanChange{
+		{TargetName: "app/new", Type: TypeGeneric, Action: PlanCreate},
+		{TargetName: "app/same", Type: TypeGeneric, Action: PlanNoop},
+		{TargetName: "app/changed", Type: TypeGeneric, Action: PlanUpdate, Changes: []string{"Persist", "CredentialBlob", "Attributes[host]"}},
+		{TargetName: "app/generated", Type: TypeGeneric, Action: PlanNoop},
+		{TargetName: "App/Wide", Type: TypeGeneric, Action: PlanNoop},
+		{TargetName: "other", Type: TypeDomainPassword, Action: PlanUpdate, Changes: []string{"CredentialBlob"}},
+		{TargetName: "rotated", Type: TypeDomainPassword, Action: PlanUpdate, Changes: []string{"Comment", "CredentialBlob"}},
+		{TargetName: "kept", Type: TypeDomainPassword, Action: PlanNoop},
+		{TargetName: "empty", Type: TypeDomainPassword, Action: PlanNoop},
+		{TargetName: "app/stale", Type: TypeGeneric, Action: PlanDelete},
+	}, plan.Changes)
+	assert.Equal(t, []byte("env-secret"), steps[0].cred.CredentialBlob)
+	assert.Equal(t, []byte("kept"), steps[3].cred.CredentialBlob)
+	assert.Equal(t, encodeUTF16LE("env-secret"), steps[4].cred.CredentialBlob)
+	assert.Equal(t, encodeUTF16LE("env-secret"), steps[5].cred.CredentialBlob)
+	assert.Len(t, steps[6].cred.CredentialBlob, DefaultGeneratedLength*2)
+	for _, change := range plan.Changes {
+		assert.NotContains(t, change.String(), "secret")
+	}
+
+	steps, err = planManifest(m, current, ManifestOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, planFromSteps(steps).Count(PlanDelete))
+}
+
+func TestPlanManifest_Invalid(t *testing.T) {
+	for name, m := range map[string]*Manifest{
+		"no target":      {Credentials: []ManifestCredential{{}}},
+		"duplicate":      {Credentials: []ManifestCredential{{TargetName: "a"}, {TargetName: "a", Type: TypeGeneric}}},
+		"two sources":    {Credentials: []ManifestCredential{{TargetName: "a", Secret: SecretSource{Env: "X", File: "y"}}}},
+		"unset variable": {Credentials: []ManifestCredential{{TargetName: "a", Secret: SecretSource{Env: "WINCRED_TEST_UNSET"}}}},
+		"encoding":       {Credentials: []ManifestCredential{{TargetName: "a", Secret: SecretSource{Encoding: "latin1"}}}},
+		"utf-8 password": {Credentials: []ManifestCredential{{TargetName: "a", Type: TypeDomainPassword, Secret: SecretSource{Encoding: "utf-8"}}}},
+	} {
+		_, err := planManifest(m, nil, ManifestOptions{})
+		assert.True(t, errors.Is(err, ErrInvalidManifest), name)
+	}
+	_, err := planManifest(&Manifest{}, nil, ManifestOptions{Prune: true})
+	assert.True(t, errors.Is(err, ErrInvalidManifest))
+}
+
+func TestUTF8ToUTF16LE(t *testing.T) {
+	for _, s := range []string{"", "secret", "päss", "🔑key"} {
+		assert.Equal(t, encodeUTF16LE(s), utf8ToUTF16LE([]byte(s)), s)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := generateSecret(&SecretGenerator{})
+	assert.Nil(t, err)
+	assert.Len(t, secret, DefaultGeneratedLength)
+
+	secret, err = generateSecret(&SecretGenerator{Length: 8, Charset: "ab"})
+	assert.Nil(t, err)
+	assert.Len(t, secret, 8)
+	assert.Empty(t, strings.Trim(string(secret), "ab"))
+}
@@ -0,0 +1,432 @@
+package wincred
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecretMode controls how the credential blob and the values of sensitive
+// attributes (see MarkSensitiveAttribute) are represented when marshaling a
+// credential to JSON or YAML.
+type SecretMode int
+
+const (
+	// SecretsOmit leaves out secrets entirely.
+	SecretsOmit SecretMode = iota
+
+	// SecretsHash replaces secrets with their length and SHA-256 hash.
+	SecretsHash
+
+	// SecretsInclude includes secrets in clear text (or base64 for binary data).
+	SecretsInclude
+)
+
+// ErrInvalidEncoding is returned when unmarshaling malformed credential data.
+var ErrInvalidEncoding = errors.New("invalid credential encoding")
+
+var persistenceNames = map[CredentialPersistence]string{
+	PersistSession:      "Session",
+	PersistLocalMachine: "LocalMachine",
+	PersistEnterprise:   "Enterprise",
+}
+
+// String returns the name of the persistence mode.
+func (t CredentialPersistence) String() string {
+	if name, ok := persistenceNames[t]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// Known persistence modes are represented by their names, others by their
+// numeric value.
+func (t CredentialPersistence) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It accepts the names of the persistence modes (case-insensitive) and
+// numeric values.
+func (t *CredentialPersistence) UnmarshalText(text []byte) error {
+	for persist, name := range persistenceNames {
+		if strings.EqualFold(name, string(text)) {
+			*t = persist
+			return nil
+		}
+	}
+	value, err := strconv.ParseUint(string(text), 0, 32)
+	if err != nil {
+		return fmt.Errorf("%w: unknown persistence %q", ErrInvalidEncoding, text)
+	}
+	*t = CredentialPersistence(value)
+	return nil
+}
+
+var typeNames = map[CredentialType]string{
+	TypeGeneric:               "Generic",
+	TypeDomainPassword:        "DomainPassword",
+	TypeDomainCertificate:     "DomainCertificate",
+	TypeDomainVisiblePassword: "DomainVisiblePassword",
+	TypeGenericCertificate:    "GenericCertificate",
+	TypeDomainExtended:        "DomainExtended",
+}
+
+// String returns the name of the credential type.
+func (t CredentialType) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// Known credential types are represented by their names, others by their
+// numeric value.
+func (t CredentialType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It accepts the names of the credential types (case-insensitive) and numeric
+// values.
+func (t *CredentialType) UnmarshalText(text []byte) error {
+	for typ, name := range typeNames {
+		if strings.EqualFold(name, string(text)) {
+			*t = typ
+			return nil
+		}
+	}
+	value, err := strconv.ParseUint(string(text), 0, 32)
+	if err != nil {
+		return fmt.Errorf("%w: unknown credential type %q", ErrInvalidEncoding, text)
+	}
+	*t = CredentialType(value)
+	return nil
+}
+
+// jsonValue is the JSON and YAML representation of a credential blob or
+// attribute value.
+type jsonValue struct {
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	Data     string `json:"data,omitempty" yaml:"data,omitempty"`
+	Length   int    `json:"length,omitempty" yaml:"length,omitempty"`
+	SHA256   string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+}
+
+type jsonAttribute struct {
+	Keyword string     `json:"keyword" yaml:"keyword"`
+	Value   *jsonValue `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// jsonCredential is the JSON and YAML representation of a credential.
+type jsonCredential struct {
+	TargetName     string                `json:"targetName" yaml:"targetName"`
+	Comment        string                `json:"comment,omitempty" yaml:"comment,omitempty"`
+	LastWritten    *time.Time            `json:"lastWritten,omitempty" yaml:"lastWritten,omitempty"`
+	CredentialBlob *jsonValue            `json:"credentialBlob,omitempty" yaml:"credentialBlob,omitempty"`
+	Attributes     []jsonAttribute       `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	TargetAlias    string                `json:"targetAlias,omitempty" yaml:"targetAlias,omitempty"`
+	UserName       string                `json:"userName,omitempty" yaml:"userName,omitempty"`
+	Persist        CredentialPersistence `json:"persist,omitempty" yaml:"persist,omitempty"`
+	Type           CredentialType        `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+// newJSONValue creates the representation of the given value.
+func newJSONValue(value []byte, secret bool, mode SecretMode) *jsonValue {
+	if secret && mode == SecretsOmit {
+		return nil
+	}
+	if secret && mode == SecretsHash {
+		sum := sha256.Sum256(value)
+		return &jsonValue{Length: len(value), SHA256: hex.EncodeToString(sum[:])}
+	}
+	if value == nil {
+		return nil
+	}
+	result := &jsonValue{Encoding: detectEncoding(value)}
+	if result.Encoding == encodingUTF16LE && !isExactUTF16LE(value) {
+		result.Encoding = encodingBase64
+	}
+	switch result.Encoding {
+	case encodingUTF16LE:
+		result.Data, _ = decodeUTF16LE(value)
+	case encodingBase64:
+		result.Data = base64.StdEncoding.EncodeToString(value)
+	default:
+		result.Data = string(value)
+	}
+	return result
+}
+
+// bytes decodes the represented value. Hashed values cannot be restored; they
+// result in nil.
+func (t *jsonValue) bytes() ([]byte, error) {
+	if t == nil || t.SHA256 != "" {
+		return nil, nil
+	}
+	switch t.Encoding {
+	case encodingUTF8, "":
+		return []byte(t.Data), nil
+	case encodingUTF16LE:
+		return encodeUTF16LE(t.Data), nil
+	case encodingBase64:
+		return base64.StdEncoding.DecodeString(t.Data)
+	}
+	return nil, fmt.Errorf("%w: unknown value encoding %q", ErrInvalidEncoding, t.Encoding)
+}
+
+func (t *Credential) toJSON(mode SecretMode) *jsonCredential {
+	result := &jsonCredential{
+		TargetName:     t.TargetName,
+		Comment:        t.Comment,
+		CredentialBlob: newJSONValue(t.CredentialBlob, true, mode),
+		TargetAlias:    t.TargetAlias,
+		UserName:       t.UserName,
+		Persist:        t.Persist,
+		Type:           t.Type,
+	}
+	if !t.LastWritten.IsZero() {
+		lastWritten := t.LastWritten
+		result.LastWritten = &lastWritten
+	}
+	for _, attr := range t.Attributes {
+		result.Attributes = append(result.Attributes, jsonAttribute{
+			Keyword: attr.Keyword,
+			Value:   newJSONValue(attr.Value, IsSensitiveAttribute(attr.Keyword), mode),
+		})
+	}
+	return result
+}
+
+func (t *Credential) fromJSON(in *jsonCredential) error {
+	blob, err := in.CredentialBlob.bytes()
+	if err != nil {
+		return err
+	}
+	result := Credential{
+		TargetName:     in.TargetName,
+		Comment:        in.Comment,
+		CredentialBlob: blob,
+		TargetAlias:    in.TargetAlias,
+		UserName:       in.UserName,
+		Persist:        in.Persist,
+		Type:           in.Type,
+	}
+	if in.LastWritten != nil {
+		result.LastWritten = *in.LastWritten
+	}
+	for _, attr := range in.Attributes {
+		value, err := attr.Value.bytes()
+		if err != nil {
+			return err
+		}
+		result.Attributes = append(result.Attributes, CredentialAttribute{Keyword: attr.Keyword, Value: value})
+	}
+	*t = result
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// The credential blob and the values of sensitive attributes are omitted; use
+// MarshalJSONMode to include them. The default is lossy: a credential
+// marshaled with json.Marshal and unmarshaled again has no credential blob,
+// and no error is reported.
+func (t Credential) MarshalJSON() ([]byte, error) {
+	return t.MarshalJSONMode(SecretsOmit)
+}
+
+// MarshalJSONMode returns the JSON encoding of the credential.
+// The given mode defines how the credential blob and the values of sensitive
+// attributes are represented. Text values are stored as strings together with
+// their encoding (UTF-8 or UTF-16), binary values are base64 encoded.
+func (t Credential) MarshalJSONMode(mode SecretMode) ([]byte, error) {
+	return json.Marshal(t.toJSON(mode))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// Omitted or hashed secrets result in a nil credential blob or attribute value
+// without error. Since MarshalJSON omits secrets, only data produced by
+// MarshalJSONMode with SecretsInclude restores the credential blob.
+func (t *Credential) UnmarshalJSON(data []byte) error {
+	var in jsonCredential
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	return t.fromJSON(&in)
+}
+
+// MarshalYAML implements the marshaler interface of gopkg.in/yaml.v2 and
+// gopkg.in/yaml.v3. Secrets are omitted like in MarshalJSON, so the default is
+// lossy as well; use MarshalYAMLMode to include them.
+func (t Credential) MarshalYAML() (interface{}, error) {
+	return t.MarshalYAMLMode(SecretsOmit)
+}
+
+// MarshalYAMLMode returns a value that YAML libraries marshal like the JSON
+// encoding of MarshalJSONMode with the given mode. The result can be passed to
+// the Marshal function of gopkg.in/yaml.v2 or gopkg.in/yaml.v3.
+func (t Credential) MarshalYAMLMode(mode SecretMode) (interface{}, error) {
+	return t.toJSON(mode), nil
+}
+
+// UnmarshalYAML implements the unmarshaler interface of gopkg.in/yaml.v2,
+// which is also supported by gopkg.in/yaml.v3. Omitted or hashed secrets
+// result in a nil credential blob or attribute value without error.
+func (t *Credential) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var in jsonCredential
+	if err := unmarshal(&in); err != nil {
+		return err
+	}
+	return t.fromJSON(&in)
+}
+
+// Binary encoding of credentials:
+// The magic bytes and the format version are followed by a sequence of fields.
+// Each field consists of a tag, the length of the data and the data itself.
+// Readers skip unknown tags, so that fields can be added without breaking
+// compatibility.
+var binaryMagic = []byte("WCRD")
+
+const binaryVersion = 1
+
+const (
+	binaryTagTargetName = iota + 1
+	binaryTagComment
+	binaryTagLastWritten
+	binaryTagCredentialBlob
+	binaryTagAttribute
+	binaryTagTargetAlias
+	binaryTagUserName
+	binaryTagPersist
+	binaryTagType
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// The compact binary encoding is meant for caches and inter-process
+// communication. It includes the credential blob in clear text.
+func (t Credential) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(binaryMagic)
+	buf.WriteByte(binaryVersion)
+	writeField := func(tag uint64, data []byte) {
+		var hdr [2 * binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(hdr[:], tag)
+		n += binary.PutUvarint(hdr[n:], uint64(len(data)))
+		buf.Write(hdr[:n])
+		buf.Write(data)
+	}
+	writeString := func(tag uint64, s string) {
+		if s != "" {
+			writeField(tag, []byte(s))
+		}
+	}
+	writeString(binaryTagTargetName, t.TargetName)
+	writeString(binaryTagComment, t.Comment)
+	if !t.LastWritten.IsZero() {
+		lastWritten, err := t.LastWritten.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		writeField(binaryTagLastWritten, lastWritten)
+	}
+	if t.CredentialBlob != nil {
+		writeField(binaryTagCredentialBlob, t.CredentialBlob)
+	}
+	for _, attr := range t.Attributes {
+		data := appendUvarint(nil, uint64(len(attr.Keyword)))
+		data = append(data, attr.Keyword...)
+		data = append(data, attr.Value...)
+		writeField(binaryTagAttribute, data)
+	}
+	writeString(binaryTagTargetAlias, t.TargetAlias)
+	writeString(binaryTagUserName, t.UserName)
+	if t.Persist != 0 {
+		writeField(binaryTagPersist, appendUvarint(nil, uint64(t.Persist)))
+	}
+	if t.Type != 0 {
+		writeField(binaryTagType, appendUvarint(nil, uint64(t.Type)))
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (t *Credential) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, binaryMagic) || len(data) <= len(binaryMagic) {
+		return fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+	if version := data[len(binaryMagic)]; version != binaryVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, version)
+	}
+	data = data[len(binaryMagic)+1:]
+	var result Credential
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("%w: malformed field tag", ErrInvalidEncoding)
+		}
+		data = data[n:]
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return fmt.Errorf("%w: malformed field length", ErrInvalidEncoding)
+		}
+		field := data[n : n+int(size)]
+		data = data[n+int(size):]
+
+		switch tag {
+		case binaryTagTargetName:
+			result.TargetName = string(field)
+		case binaryTagComment:
+			result.Comment = string(field)
+		case binaryTagLastWritten:
+			if err := result.LastWritten.UnmarshalBinary(field); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+			}
+		case binaryTagCredentialBlob:
+			result.CredentialBlob = append([]byte{}, field...)
+		case binaryTagAttribute:
+			size, n := binary.Uvarint(field)
+			if n <= 0 || size > uint64(len(field)-n) {
+				return fmt.Errorf("%w: malformed attribute", ErrInvalidEncoding)
+			}
+			result.Attributes = append(result.Attributes, CredentialAttribute{
+				Keyword: string(field[n : n+int(size)]),
+				Value:   append([]byte{}, field[n+int(size):]...),
+			})
+		case binaryTagTargetAlias:
+			result.TargetAlias = string(field)
+		case binaryTagUserName:
+			result.UserName = string(field)
+		case binaryTagPersist:
+			persist, n := binary.Uvarint(field)
+			if n <= 0 {
+				return fmt.Errorf("%w: malformed persistence", ErrInvalidEncoding)
+			}
+			result.Persist = CredentialPersistence(persist)
+		case binaryTagType:
+			typ, n := binary.Uvarint(field)
+			if n <= 0 {
+				return fmt.Errorf("%w: malformed credential type", ErrInvalidEncoding)
+			}
+			result.Type = CredentialType(typ)
+		}
+	}
+	*t = result
+	return nil
+}
+
+// appendUvarint appends the varint encoding of the given value to the slice.
+func appendUvarint(b []byte, value uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], value)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,211 @@
+package wincred
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialPersistence_Text(t *testing.T) {
+	for _, persist := range []CredentialPersistence{PersistSession, PersistLocalMachine, PersistEnterprise, 0, 42} {
+		text, err := persist.MarshalText()
+		assert.Nil(t, err)
+		var res CredentialPersistence
+		assert.Nil(t, res.UnmarshalText(text))
+		assert.Equal(t, persist, res)
+	}
+	assert.Equal(t, "Enterprise", PersistEnterprise.String())
+	assert.Equal(t, "42", CredentialPersistence(42).String())
+
+	var res CredentialPersistence
+	assert.Nil(t, res.UnmarshalText([]byte("localmachine")))
+	assert.Equal(t, PersistLocalMachine, res)
+	assert.True(t, errors.Is(res.UnmarshalText([]byte("forever")), ErrInvalidEncoding))
+}
+
+func TestCredentialType_Text(t *testing.T) {
+	for _, typ := range []CredentialType{TypeGeneric, TypeDomainPassword, TypeDomainExtended, 0, 42} {
+		text, err := typ.MarshalText()
+		assert.Nil(t, err)
+		var res CredentialType
+		assert.Nil(t, res.UnmarshalText(text))
+		assert.Equal(t, typ, res)
+	}
+	assert.Equal(t, "DomainPassword", TypeDomainPassword.String())
+	var res CredentialType
+	assert.True(t, errors.Is(res.UnmarshalText([]byte("magic")), ErrInvalidEncoding))
+}
+
+func TestCredential_MarshalJSON(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	cred := fixtureSecretCredential()
+	data, err := json.Marshal(cred)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"targetName": "Foo",
+		"lastWritten": "2024-01-02T03:04:05Z",
+		"attributes": [
+			{"keyword": "Token"},
+			{"keyword": "Plain", "value": {"encoding": "base64", "data": "AQI="}}
+		],
+		"userName": "Nobody",
+		"persist": "LocalMachine",
+		"type": "Generic"
+	}`, string(data))
+}
+
+func TestCredential_MarshalJSON_OmitsSecrets(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	cred := fixtureSecretCredential()
+	data, err := json.Marshal(cred)
+	assert.Nil(t, err)
+	var res Credential
+	assert.Nil(t, json.Unmarshal(data, &res))
+	assert.Equal(t, cred.TargetName, res.TargetName)
+	assert.Nil(t, res.CredentialBlob)
+	assert.Nil(t, res.attribute("Token").Value)
+	assert.Equal(t, []byte{1, 2}, res.attribute("Plain").Value)
+}
+
+func TestCredential_MarshalJSONMode_Hash(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	cred := fixtureSecretCredential()
+	data, err := cred.MarshalJSONMode(SecretsHash)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), `"credentialBlob":{"length":9,"sha256":"b9d1d013`)
+
+	var res Credential
+	assert.Nil(t, json.Unmarshal(data, &res))
+	assert.Nil(t, res.CredentialBlob)
+	assert.Nil(t, res.attribute("Token").Value)
+}
+
+func TestCredential_MarshalJSONMode_Include(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	cred := fixtureSecretCredential()
+	cred.Attributes = append(cred.Attributes, CredentialAttribute{Keyword: "Wide", Value: encodeUTF16LE("wide")})
+	data, err := cred.MarshalJSONMode(SecretsInclude)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"credentialBlob":{"encoding":"utf-8","data":"my secret"}`)
+	assert.Contains(t, string(data), `{"keyword":"Wide","value":{"encoding":"utf-16le","data":"wide"}}`)
+
+	var res Credential
+	assert.Nil(t, json.Unmarshal(data, &res))
+	assert.Equal(t, cred.CredentialBlob, res.CredentialBlob)
+	assert.Equal(t, cred.Attributes, res.Attributes)
+	assert.True(t, cred.LastWritten.Equal(res.LastWritten))
+	assert.Equal(t, cred.Persist, res.Persist)
+	assert.Equal(t, cred.UserName, res.UserName)
+}
+
+func TestCredential_MarshalJSONMode_ExactBytes(t *testing.T) {
+	for _, blob := range [][]byte{
+		[]byte("a\x00b\x00\x00\x00"),
+		[]byte("\x00\x00"),
+		encodeUTF16LE("wide"),
+		{0xff, 0x00, 0x01},
+	} {
+		cred := &Credential{TargetName: "Foo", CredentialBlob: blob}
+		data, err := cred.MarshalJSONMode(SecretsInclude)
+		assert.Nil(t, err)
+		var res Credential
+		assert.Nil(t, json.Unmarshal(data, &res))
+		assert.Equal(t, blob, res.CredentialBlob, string(data))
+	}
+}
+
+func TestCredential_UnmarshalJSON_Invalid(t *testing.T) {
+	var res Credential
+	err := json.Unmarshal([]byte(`{"credentialBlob":{"encoding":"rot13","data":"x"}}`), &res)
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+	err = json.Unmarshal([]byte(`{"persist":"forever"}`), &res)
+	assert.NotNil(t, err)
+}
+
+func TestCredential_YAML(t *testing.T) {
+	cred := fixtureSecretCredential()
+	out, err := cred.MarshalYAML()
+	assert.Nil(t, err)
+	var res Credential
+	err = res.UnmarshalYAML(func(v interface{}) error {
+		data, _ := json.Marshal(out)
+		return json.Unmarshal(data, v)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, cred.TargetName, res.TargetName)
+	assert.Nil(t, res.CredentialBlob)
+}
+
+func TestCredential_MarshalYAMLMode(t *testing.T) {
+	cred := fixtureSecretCredential()
+	out, err := cred.MarshalYAMLMode(SecretsInclude)
+	assert.Nil(t, err)
+	var res Credential
+	err = res.UnmarshalYAML(func(v interface{}) error {
+		data, _ := json.Marshal(out)
+		return json.Unmarshal(data, v)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, cred.CredentialBlob, res.CredentialBlob)
+	assert.Equal(t, cred.Attributes, res.Attributes)
+}
+
+func TestCredential_MarshalBinary(t *testing.T) {
+	cred := fixtureSecretCredential()
+	cred.Comment = "Bar"
+	cred.TargetAlias = "MyAlias"
+	cred.LastWritten = time.Now()
+	data, err := cred.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("WCRD\x01"), data[:5])
+
+	var res Credential
+	assert.Nil(t, res.UnmarshalBinary(data))
+	assert.Equal(t, cred.TargetName, res.TargetName)
+	assert.Equal(t, cred.Comment, res.Comment)
+	assert.True(t, cred.LastWritten.Equal(res.LastWritten))
+	assert.Equal(t, cred.CredentialBlob, res.CredentialBlob)
+	assert.Equal(t, cred.Attributes, res.Attributes)
+	assert.Equal(t, cred.TargetAlias, res.TargetAlias)
+	assert.Equal(t, cred.UserName, res.UserName)
+	assert.Equal(t, cred.Persist, res.Persist)
+	assert.Equal(t, cred.Type, res.Type)
+}
+
+func TestCredential_MarshalBinary_Empty(t *testing.T) {
+	data, err := new(Credential).MarshalBinary()
+	assert.Nil(t, err)
+	var res Credential
+	assert.Nil(t, res.UnmarshalBinary(data))
+	assert.Equal(t, Credential{}, res)
+}
+
+func TestCredential_UnmarshalBinary_UnknownField(t *testing.T) {
+	data := append([]byte("WCRD\x01"), 99, 2, 'x', 'y', binaryTagTargetName, 3, 'F', 'o', 'o')
+	var res Credential
+	assert.Nil(t, res.UnmarshalBinary(data))
+	assert.Equal(t, "Foo", res.TargetName)
+}
+
+func TestCredential_UnmarshalBinary_Invalid(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("WCRD"),
+		[]byte("XXXX\x01"),
+		[]byte("WCRD\x02"),
+		[]byte("WCRD\x01\x01\x05Foo"),
+		[]byte("WCRD\x01\x05\x01\x09"),
+	}
+	for _, input := range inputs {
+		var res Credential
+		assert.True(t, errors.Is(res.UnmarshalBinary(input), ErrInvalidEncoding), "%q", input)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package wincred
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// memLock locks the pages of the given buffer into physical memory.
+func memLock(b []byte) error {
+	return windows.VirtualLock(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+}
+
+// memUnlock unlocks the pages of the given buffer.
+func memUnlock(b []byte) error {
+	return windows.VirtualUnlock(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+}
@@ -0,0 +1,16 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package wincred
+
+import "golang.org/x/sys/unix"
+
+// memLock locks the pages of the given buffer into physical memory.
+func memLock(b []byte) error {
+	return unix.Mlock(b)
+}
+
+// memUnlock unlocks the pages of the given buffer.
+func memUnlock(b []byte) error {
+	return unix.Munlock(b)
+}
@@ -0,0 +1,12 @@
+//go:build !windows && !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !windows,!aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package wincred
+
+func memLock(b []byte) error {
+	return ErrLockNotSupported
+}
+
+func memUnlock(b []byte) error {
+	return ErrLockNotSupported
+}
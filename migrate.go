@@ -0,0 +1,218 @@
+package wincred
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoMigration is returned when a credential cannot be upgraded because
+// the migration from its schema version is missing.
+var ErrNoMigration = errors.New("no migration registered")
+
+// MigrationFunc upgrades a credential blob by one schema version.
+type MigrationFunc func(blob []byte) ([]byte, error)
+
+// migrations holds the registered migrations per target name prefix and
+// schema version they upgrade from.
+var migrations = struct {
+	sync.RWMutex
+	prefixes map[string]map[int]MigrationFunc
+}{prefixes: make(map[string]map[int]MigrationFunc)}
+
+// RegisterMigration registers a migration that upgrades the blobs of generic
+// credentials whose target name starts with the given prefix from schema
+// version from to version from+1. Credentials without schema version
+// attribute have version 0. If several prefixes match a target name, only the
+// migrations of the longest one apply.
+func RegisterMigration(prefix string, from int, migrate MigrationFunc) {
+	migrations.Lock()
+	defer migrations.Unlock()
+	if migrations.prefixes[prefix] == nil {
+		migrations.prefixes[prefix] = make(map[int]MigrationFunc)
+	}
+	migrations.prefixes[prefix][from] = migrate
+}
+
+// migrationsFor returns the migrations that apply to the given target name.
+func migrationsFor(targetName string) map[int]MigrationFunc {
+	migrations.RLock()
+	defer migrations.RUnlock()
+	var result map[int]MigrationFunc
+	longest := -1
+	for prefix, funcs := range migrations.prefixes {
+		if strings.HasPrefix(targetName, prefix) && len(prefix) > longest {
+			result, longest = funcs, len(prefix)
+		}
+	}
+	return result
+}
+
+// latestVersion returns the version the given migrations upgrade to.
+func latestVersion(funcs map[int]MigrationFunc) int {
+	latest := 0
+	for from := range funcs {
+		if from+1 > latest {
+			latest = from + 1
+		}
+	}
+	return latest
+}
+
+// Migrate upgrades the blob of the given credential in memory to the latest
+// schema version registered for its target name, and updates its schema
+// version attribute. It returns the version the credential had before and
+// the version it has now.
+func Migrate(cred *Credential) (from, to int, err error) {
+	from, err = storedSchemaVersion(cred)
+	if err != nil {
+		return from, from, err
+	}
+	funcs := migrationsFor(cred.TargetName)
+	latest := latestVersion(funcs)
+	version := from
+	blob := cred.CredentialBlob
+	for ; version < latest; version++ {
+		migrate, ok := funcs[version]
+		if !ok {
+			err = fmt.Errorf("%w: %q from version %d", ErrNoMigration, cred.TargetName, version)
+			break
+		}
+		upgraded, migrateErr := migrate(blob)
+		if migrateErr != nil {
+			err = fmt.Errorf("migrating %q from version %d: %w", cred.TargetName, version, migrateErr)
+			break
+		}
+		blob = upgraded
+	}
+	if err != nil {
+		return from, from, err
+	}
+	if version != from {
+		cred.CredentialBlob = blob
+		cred.setAttribute(AttributeSchemaVersion, []byte(strconv.Itoa(version)))
+	}
+	return from, version, nil
+}
+
+// GetMigrated fetches the generic credential with the given target name and
+// upgrades it to the latest registered schema version. If writeBack is set,
+// an upgraded credential is written back to the credential manager.
+func GetMigrated(targetName string, writeBack bool) (*GenericCredential, error) {
+	cred, err := GetGenericCredential(targetName)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := Migrate(&cred.Credential)
+	if err != nil {
+		cred.Wipe()
+		return nil, err
+	}
+	if writeBack && from != to {
+		if err := cred.Write(); err != nil {
+			cred.Wipe()
+			return nil, err
+		}
+	}
+	return cred, nil
+}
+
+// MigrationResult describes the migration of a single credential.
+type MigrationResult struct {
+	TargetName string
+	// From and To are the schema versions before and after the migration.
+	// They are equal for credentials that did not need a migration.
+	From int
+	To   int
+	// Err is the error that occurred if the migration failed.
+	Err error
+}
+
+// Migrated reports whether the credential was upgraded.
+func (t MigrationResult) Migrated() bool {
+	return t.Err == nil && t.From != t.To
+}
+
+// MigrationReport lists the outcome of MigrateAll.
+type MigrationReport struct {
+	Results []MigrationResult
+}
+
+// Migrated returns the number of upgraded credentials.
+func (t *MigrationReport) Migrated() int {
+	count := 0
+	for _, result := range t.Results {
+		if result.Migrated() {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed returns the number of credentials whose migration failed.
+func (t *MigrationReport) Failed() int {
+	count := 0
+	for _, result := range t.Results {
+		if result.Err != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// migrateCredentials upgrades the given credentials in memory. Only generic
+// credentials with registered migrations are considered.
+func migrateCredentials(creds []*Credential) *MigrationReport {
+	report := new(MigrationReport)
+	for _, cred := range creds {
+		if cred.Type != TypeGeneric || migrationsFor(cred.TargetName) == nil {
+			continue
+		}
+		result := MigrationResult{TargetName: cred.TargetName}
+		result.From, result.To, result.Err = Migrate(cred)
+		report.Results = append(report.Results, result)
+	}
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		return report.Results[i].TargetName < report.Results[j].TargetName
+	})
+	return report
+}
+
+// MigrateAll upgrades all generic credentials matching the given filter (see
+// FilteredList) to the latest schema version registered for their target
+// names and writes them back. An empty filter considers all credentials.
+// With dryRun set, the credentials are only upgraded in memory and the
+// report lists what would be migrated.
+func MigrateAll(filter string, dryRun bool) (*MigrationReport, error) {
+	creds, err := listCredentials(filter)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, cred := range creds {
+			cred.Wipe()
+		}
+	}()
+	report := migrateCredentials(creds)
+	if dryRun {
+		return report, nil
+	}
+	byTarget := make(map[string]*Credential)
+	for _, cred := range creds {
+		if cred.Type == TypeGeneric {
+			byTarget[cred.TargetName] = cred
+		}
+	}
+	for i, result := range report.Results {
+		if !result.Migrated() {
+			continue
+		}
+		if err := writeCredential(byTarget[result.TargetName]); err != nil {
+			report.Results[i].To, report.Results[i].Err = result.From, err
+		}
+	}
+	return report, nil
+}
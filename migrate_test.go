@@ -0,0 +1,87 @@
+package wincred
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func registerFixtureMigrations(t *testing.T, prefix string) {
+	RegisterMigration(prefix, 0, func(blob []byte) ([]byte, error) {
+		return bytes.ReplaceAll(blob, []byte(`"url"`), []byte(`"endpoint"`)), nil
+	})
+	RegisterMigration(prefix, 1, func(blob []byte) ([]byte, error) {
+		if bytes.Contains(blob, []byte("broken")) {
+			return nil, errors.New("broken payload")
+		}
+		return bytes.ReplaceAll(blob, []byte(`"endpoint"`), []byte(`"Endpoint"`)), nil
+	})
+	t.Cleanup(func() {
+		migrations.Lock()
+		delete(migrations.prefixes, prefix)
+		migrations.Unlock()
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	registerFixtureMigrations(t, "test-migrate/")
+	cred := &Credential{TargetName: "test-migrate/app", CredentialBlob: []byte(`{"url":"x"}`), Type: TypeGeneric}
+	from, to, err := Migrate(cred)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, from)
+	assert.Equal(t, 2, to)
+	assert.Equal(t, `{"Endpoint":"x"}`, string(cred.CredentialBlob))
+	version, _ := cred.GetAttribute(AttributeSchemaVersion)
+	assert.Equal(t, "2", string(version))
+
+	from, to, err = Migrate(cred)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, from)
+	assert.Equal(t, 2, to)
+
+	other := &Credential{TargetName: "other/app", CredentialBlob: []byte(`{"url":"x"}`)}
+	_, to, err = Migrate(other)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, to)
+	assert.Equal(t, `{"url":"x"}`, string(other.CredentialBlob))
+}
+
+func TestMigrate_Errors(t *testing.T) {
+	registerFixtureMigrations(t, "test-migrate/")
+	cred := &Credential{TargetName: "test-migrate/app", CredentialBlob: []byte(`{"url":"broken"}`)}
+	_, to, err := Migrate(cred)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, to)
+	assert.Equal(t, `{"url":"broken"}`, string(cred.CredentialBlob))
+
+	RegisterMigration("test-migrate/", 3, func(blob []byte) ([]byte, error) { return blob, nil })
+	cred = &Credential{TargetName: "test-migrate/app", CredentialBlob: []byte(`{}`)}
+	_, _, err = Migrate(cred)
+	assert.True(t, errors.Is(err, ErrNoMigration))
+}
+
+func TestMigrateCredentials_Report(t *testing.T) {
+	registerFixtureMigrations(t, "test-migrate/")
+	creds := []*Credential{
+		{TargetName: "test-migrate/b", CredentialBlob: []byte(`{"url":"broken"}`), Type: TypeGeneric},
+		{TargetName: "test-migrate/a", CredentialBlob: []byte(`{"url":"x"}`), Type: TypeGeneric},
+		{TargetName: "test-migrate/c", Type: TypeDomainPassword},
+		{TargetName: "unrelated", Type: TypeGeneric},
+	}
+	report := migrateCredentials(creds)
+	assert.Len(t, report.Results, 2)
+	assert.Equal(t, "test-migrate/a", report.Results[0].TargetName)
+	assert.Equal(t, 1, report.Migrated())
+	assert.Equal(t, 1, report.Failed())
+}
+
+func TestDecodeTyped_Migrates(t *testing.T) {
+	registerFixtureMigrations(t, "test-migrate/")
+	cred := &Credential{TargetName: "test-migrate/app", CredentialBlob: []byte(`{"url":"x"}`)}
+	cred.setAttribute(AttributeCodec, []byte("json"))
+	value, err := decodeTyped[fixtureVersionedConfig](cred, CodecJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", value.Endpoint)
+}
@@ -0,0 +1,297 @@
+package wincred
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PathSeparator separates the segments of hierarchical target names.
+const PathSeparator = "/"
+
+// ErrTargetExists is returned when moving or copying credentials onto target
+// names that are already in use.
+var ErrTargetExists = errors.New("target already exists")
+
+// escapeSegment escapes a path segment, so that separators and wildcards in
+// segment names are safe.
+func escapeSegment(segment string) string {
+	return strings.ReplaceAll(escapeKey(segment), PathSeparator, "%2F")
+}
+
+// JoinPath returns the target name of the given path segments, escaping
+// separators, wildcards and percent signs in the segments.
+func JoinPath(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = escapeSegment(segment)
+	}
+	return strings.Join(escaped, PathSeparator)
+}
+
+// SplitPath returns the unescaped segments of a target name created by JoinPath.
+func SplitPath(targetName string) ([]string, error) {
+	if targetName == "" {
+		return nil, nil
+	}
+	segments := strings.Split(targetName, PathSeparator)
+	for i, segment := range segments {
+		var err error
+		if segments[i], err = unescapeKey(segment); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+// NamespaceEntry is an immediate child of a namespace path.
+type NamespaceEntry struct {
+	// Name is the unescaped name of the child segment.
+	Name string
+	// Leaf indicates that a credential is stored at the child path.
+	Leaf bool
+	// Branch indicates that credentials are stored below the child path.
+	Branch bool
+}
+
+// Namespace treats the target names of generic credentials as a tree of
+// "/"-separated paths below a root path. All paths are given as unescaped
+// segments relative to the root.
+type Namespace struct {
+	root []string
+}
+
+// NewNamespace returns the namespace below the given root path.
+func NewNamespace(root ...string) *Namespace {
+	return &Namespace{root: append([]string{}, root...)}
+}
+
+// TargetName returns the target name of the given path.
+func (t *Namespace) TargetName(path ...string) string {
+	return JoinPath(append(append([]string{}, t.root...), path...)...)
+}
+
+// subtreePrefix returns the target name prefix of the credentials below the
+// given path.
+func (t *Namespace) subtreePrefix(path []string) string {
+	if len(t.root)+len(path) == 0 {
+		return ""
+	}
+	return t.TargetName(path...) + PathSeparator
+}
+
+// subtree returns the generic credentials stored at and below the given path,
+// sorted by target name.
+func (t *Namespace) subtree(path []string) ([]*Credential, error) {
+	prefix := t.subtreePrefix(path)
+	creds, err := listPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	result := filterGeneric(creds)
+	if len(t.root)+len(path) > 0 {
+		cred, err := GetGenericCredential(t.TargetName(path...))
+		if err == nil {
+			result = append(result, &cred.Credential)
+		} else if !errors.Is(err, ErrElementNotFound) {
+			return nil, err
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].TargetName < result[j].TargetName })
+	return result, nil
+}
+
+func filterGeneric(creds []*Credential) []*Credential {
+	var result []*Credential
+	for _, cred := range creds {
+		if cred.Type == TypeGeneric {
+			result = append(result, cred)
+		}
+	}
+	return result
+}
+
+// children returns the immediate children of the prefix among the given
+// credentials, sorted by name. Children whose names are not valid path
+// segments are skipped.
+func children(prefix string, creds []*Credential) []NamespaceEntry {
+	entries := make(map[string]*NamespaceEntry)
+	for _, cred := range creds {
+		if !hasPrefixFold(cred.TargetName, prefix) || len(cred.TargetName) == len(prefix) {
+			continue
+		}
+		rest := cred.TargetName[len(prefix):]
+		segment, _, branch := strings.Cut(rest, PathSeparator)
+		name, err := unescapeKey(segment)
+		if err != nil {
+			continue
+		}
+		entry := entries[name]
+		if entry == nil {
+			entry = &NamespaceEntry{Name: name}
+			entries[name] = entry
+		}
+		if branch {
+			entry.Branch = true
+		} else {
+			entry.Leaf = true
+		}
+	}
+	result := make([]NamespaceEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Children lists the immediate children of the given path.
+func (t *Namespace) Children(path ...string) ([]NamespaceEntry, error) {
+	prefix := t.subtreePrefix(path)
+	creds, err := listPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeCredentials(creds)
+	return children(prefix, filterGeneric(creds)), nil
+}
+
+// Walk calls fn for the credential stored at the given path, if any, and for
+// all credentials below it, in the order of their target names. The path
+// passed to fn is relative to the namespace root. The credential passed to fn
+// is wiped after fn returns; fn must copy any data it keeps. Walking stops at
+// the first error returned by fn. Credentials whose target names are not
+// valid paths, like those not created with JoinPath, are skipped.
+func (t *Namespace) Walk(path []string, fn func(path []string, cred *GenericCredential) error) error {
+	creds, err := t.subtree(path)
+	if err != nil {
+		return err
+	}
+	defer wipeCredentials(creds)
+	return walk(len(t.root), creds, fn)
+}
+
+// walk calls fn for the given credentials with their paths relative to a
+// root of the given depth, skipping credentials that are not valid paths.
+func walk(root int, creds []*Credential, fn func(path []string, cred *GenericCredential) error) error {
+	for _, cred := range creds {
+		segments, err := SplitPath(cred.TargetName)
+		if err != nil || len(segments) < root {
+			continue
+		}
+		if err := fn(segments[root:], &GenericCredential{Credential: *cred}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func wipeCredentials(creds []*Credential) {
+	for _, cred := range creds {
+		cred.Wipe()
+	}
+}
+
+// rebase returns the target name of a credential moved from one path to
+// another. Target names are compared case-insensitively, like Windows does.
+func (t *Namespace) rebase(targetName string, from, to []string) (string, error) {
+	oldBase, newBase := t.TargetName(from...), t.TargetName(to...)
+	if !hasPrefixFold(targetName, oldBase) {
+		return "", fmt.Errorf("%w: %q is not below %q", ErrInvalidParameter, targetName, oldBase)
+	}
+	return newBase + targetName[len(oldBase):], nil
+}
+
+// Copy copies the credential at the given path and all credentials below it
+// to the destination path. It fails with ErrTargetExists without copying
+// anything if any of the destination target names is in use.
+func (t *Namespace) Copy(from, to []string) (int, error) {
+	return t.copy(from, to, false)
+}
+
+// Move moves the credential at the given path and all credentials below it
+// to the destination path. It fails with ErrTargetExists without moving
+// anything if any of the destination target names is in use.
+func (t *Namespace) Move(from, to []string) (int, error) {
+	return t.copy(from, to, true)
+}
+
+func (t *Namespace) copy(from, to []string, remove bool) (int, error) {
+	if isPathPrefix(from, to) {
+		return 0, fmt.Errorf("cannot copy %q into itself", t.TargetName(from...))
+	}
+	creds, err := t.subtree(from)
+	if err != nil {
+		return 0, err
+	}
+	defer wipeCredentials(creds)
+	targets := make([]string, len(creds))
+	for i, cred := range creds {
+		target, err := t.rebase(cred.TargetName, from, to)
+		if err != nil {
+			return 0, err
+		}
+		targets[i] = target
+		_, err = GetGenericCredential(target)
+		if err == nil {
+			return 0, fmt.Errorf("%w: %q", ErrTargetExists, target)
+		}
+		if !errors.Is(err, ErrElementNotFound) {
+			return 0, err
+		}
+	}
+	count := 0
+	for i, cred := range creds {
+		copied := GenericCredential{Credential: *cred}
+		copied.TargetName = targets[i]
+		if err := copied.Write(); err != nil {
+			return count, err
+		}
+		if remove {
+			if err := (&GenericCredential{Credential: *cred}).Delete(); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+// isPathPrefix reports whether prefix is a prefix of path.
+func isPathPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DeleteAll deletes the credential at the given path and all credentials
+// below it, and returns the number of deleted credentials.
+func (t *Namespace) DeleteAll(path ...string) (int, error) {
+	if len(t.root)+len(path) == 0 {
+		return 0, fmt.Errorf("%w: refusing to delete all credentials", ErrInvalidParameter)
+	}
+	creds, err := t.subtree(path)
+	if err != nil {
+		return 0, err
+	}
+	defer wipeCredentials(creds)
+	count := 0
+	for _, cred := range creds {
+		err := (&GenericCredential{Credential: *cred}).Delete()
+		if errors.Is(err, ErrElementNotFound) {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,75 @@
+package wincred
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinSplitPath(t *testing.T) {
+	assert.Equal(t, "corp/app/prod/db", JoinPath("corp", "app", "prod", "db"))
+	assert.Equal(t, "corp/a%2Fb/%2A%25", JoinPath("corp", "a/b", "*%"))
+	segments, err := SplitPath("corp/a%2Fb/%2A%25")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"corp", "a/b", "*%"}, segments)
+}
+
+func TestNamespace_TargetName(t *testing.T) {
+	ns := NewNamespace("corp", "app")
+	assert.Equal(t, "corp/app/prod/db", ns.TargetName("prod", "db"))
+	assert.Equal(t, "corp/app/", ns.subtreePrefix(nil))
+	assert.Equal(t, "", NewNamespace().subtreePrefix(nil))
+
+	target, err := ns.rebase("corp/app/prod/db", []string{"prod"}, []string{"staging"})
+	assert.Nil(t, err)
+	assert.Equal(t, "corp/app/staging/db", target)
+	target, err = ns.rebase("Corp/App/Prod/db", []string{"prod"}, []string{"staging"})
+	assert.Nil(t, err)
+	assert.Equal(t, "corp/app/staging/db", target)
+	_, err = ns.rebase("corp/app/dev/db", []string{"prod"}, []string{"staging"})
+	assert.True(t, errors.Is(err, ErrInvalidParameter))
+}
+
+func TestNamespace_Children(t *testing.T) {
+	creds := []*Credential{
+		{TargetName: "corp/app/prod/db"},
+		{TargetName: "corp/app/prod"},
+		{TargetName: "Corp/App/prod/cache"},
+		{TargetName: "corp/app/a%2Fb"},
+		{TargetName: "corp/app/"},
+		{TargetName: "corp/app/100%"},
+	}
+	entries := children("corp/app/", creds)
+	assert.Equal(t, []NamespaceEntry{
+		{Name: "a/b", Leaf: true},
+		{Name: "prod", Leaf: true, Branch: true},
+	}, entries)
+}
+
+func TestNamespace_Walk(t *testing.T) {
+	creds := []*Credential{
+		{TargetName: "corp/app/db"},
+		{TargetName: "corp/app/100%"},
+		{TargetName: "corp/app/a%2Fb/c"},
+		{TargetName: "corp/app/bad%zz/c"},
+	}
+	var walked [][]string
+	err := walk(2, creds, func(path []string, cred *GenericCredential) error {
+		walked = append(walked, path)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"db"}, {"a/b", "c"}}, walked)
+
+	stop := errors.New("stop")
+	err = walk(1, creds, func(path []string, cred *GenericCredential) error { return stop })
+	assert.Equal(t, stop, err)
+}
+
+func TestIsPathPrefix(t *testing.T) {
+	assert.True(t, isPathPrefix([]string{"a"}, []string{"a", "b"}))
+	assert.True(t, isPathPrefix(nil, []string{"a"}))
+	assert.False(t, isPathPrefix([]string{"a", "b"}, []string{"a"}))
+	assert.False(t, isPathPrefix([]string{"b"}, []string{"a", "b"}))
+}
@@ -0,0 +1,596 @@
+package wincred
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PasswordFormat identifies an interchange format of password managers.
+type PasswordFormat int
+
+const (
+	// FormatKeePassXML is the XML export format of KeePass 2.
+	FormatKeePassXML PasswordFormat = iota + 1
+
+	// FormatBitwardenJSON is the unencrypted JSON export format of Bitwarden.
+	FormatBitwardenJSON
+
+	// FormatCSV is a generic CSV format with the columns target, username,
+	// password, comment and attributes.
+	FormatCSV
+)
+
+// ErrUnsupportedFormat is returned for unknown password formats.
+var ErrUnsupportedFormat = errors.New("unsupported password format")
+
+// Keys of the custom fields that carry credential properties without
+// equivalent in the password manager formats. The prefix differs from the
+// "wincred:" prefix of attributes used by this package, so that these
+// attributes are exported as regular fields.
+const (
+	passwordFieldPrefix   = "wincred-export:"
+	passwordFieldEncoding = passwordFieldPrefix + "encoding"
+	passwordFieldType     = passwordFieldPrefix + "type"
+	passwordFieldPersist  = passwordFieldPrefix + "persist"
+	passwordFieldAlias    = passwordFieldPrefix + "alias"
+	passwordFieldBinary   = passwordFieldPrefix + "binary"
+)
+
+// passwordField is a custom field of a password manager entry.
+type passwordField struct {
+	Key       string
+	Value     string
+	Protected bool
+}
+
+// passwordEntry is the common representation of an entry of the supported
+// password manager formats.
+type passwordEntry struct {
+	Title        string
+	UserName     string
+	Password     string
+	URL          string
+	Notes        string
+	Fields       []passwordField
+	LastModified time.Time
+}
+
+// newPasswordEntry maps a credential to a password manager entry.
+// Text credential blobs are decoded (UTF-8 or UTF-16), binary ones are base64
+// encoded. Attributes become custom fields.
+func newPasswordEntry(cred *Credential) passwordEntry {
+	entry := passwordEntry{
+		Title:        cred.TargetName,
+		UserName:     cred.UserName,
+		URL:          targetURL(cred.TargetName),
+		Notes:        cred.Comment,
+		LastModified: cred.LastWritten,
+	}
+	encoding := detectEncoding(cred.CredentialBlob)
+	if encoding == encodingUTF16LE && !isExactUTF16LE(cred.CredentialBlob) {
+		encoding = encodingBase64
+	}
+	switch encoding {
+	case encodingUTF8:
+		entry.Password = string(cred.CredentialBlob)
+	case encodingUTF16LE:
+		entry.Password, _ = decodeUTF16LE(cred.CredentialBlob)
+	default:
+		entry.Password = base64.StdEncoding.EncodeToString(cred.CredentialBlob)
+	}
+	var binary []string
+	for _, attr := range cred.Attributes {
+		value := string(attr.Value)
+		if detectEncoding(attr.Value) != encodingUTF8 {
+			value = base64.StdEncoding.EncodeToString(attr.Value)
+			binary = append(binary, attr.Keyword)
+		}
+		entry.Fields = append(entry.Fields, passwordField{
+			Key:       attr.Keyword,
+			Value:     value,
+			Protected: IsSensitiveAttribute(attr.Keyword),
+		})
+	}
+	addField := func(key, value string) {
+		entry.Fields = append(entry.Fields, passwordField{Key: key, Value: value})
+	}
+	if encoding != encodingUTF8 {
+		addField(passwordFieldEncoding, encoding)
+	}
+	if cred.Type != 0 && cred.Type != TypeGeneric {
+		addField(passwordFieldType, cred.Type.String())
+	}
+	if cred.Persist != 0 && cred.Persist != PersistLocalMachine {
+		addField(passwordFieldPersist, cred.Persist.String())
+	}
+	if cred.TargetAlias != "" {
+		addField(passwordFieldAlias, cred.TargetAlias)
+	}
+	if len(binary) > 0 {
+		addField(passwordFieldBinary, strings.Join(binary, ","))
+	}
+	return entry
+}
+
+// targetURL returns the URL a target name refers to, if any.
+func targetURL(targetName string) string {
+	candidate := strings.TrimPrefix(targetName, gitTargetPrefix)
+	if u, err := url.Parse(candidate); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return candidate
+	}
+	return ""
+}
+
+// credential maps the password manager entry back to a credential.
+func (t *passwordEntry) credential() (*Credential, error) {
+	cred := &Credential{
+		TargetName:  t.Title,
+		UserName:    t.UserName,
+		Comment:     t.Notes,
+		LastWritten: t.LastModified,
+		Persist:     PersistLocalMachine,
+		Type:        TypeGeneric,
+	}
+	if cred.TargetName == "" {
+		cred.TargetName = t.URL
+	}
+	encoding := encodingUTF8
+	binary := make(map[string]bool)
+	for _, field := range t.Fields {
+		switch field.Key {
+		case passwordFieldEncoding:
+			encoding = field.Value
+		case passwordFieldType:
+			if err := cred.Type.UnmarshalText([]byte(field.Value)); err != nil {
+				return nil, err
+			}
+		case passwordFieldPersist:
+			if err := cred.Persist.UnmarshalText([]byte(field.Value)); err != nil {
+				return nil, err
+			}
+		case passwordFieldAlias:
+			cred.TargetAlias = field.Value
+		case passwordFieldBinary:
+			for _, keyword := range strings.Split(field.Value, ",") {
+				binary[keyword] = true
+			}
+		}
+	}
+	var err error
+	switch encoding {
+	case encodingUTF8:
+		cred.CredentialBlob = []byte(t.Password)
+	case encodingUTF16LE:
+		cred.CredentialBlob = encodeUTF16LE(t.Password)
+	case encodingBase64:
+		if cred.CredentialBlob, err = base64.StdEncoding.DecodeString(t.Password); err != nil {
+			return nil, fmt.Errorf("%w: password of %q: %v", ErrInvalidEncoding, t.Title, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown encoding %q of %q", ErrInvalidEncoding, encoding, t.Title)
+	}
+	for _, field := range t.Fields {
+		if strings.HasPrefix(field.Key, passwordFieldPrefix) {
+			continue
+		}
+		value := []byte(field.Value)
+		if binary[field.Key] {
+			if value, err = base64.StdEncoding.DecodeString(field.Value); err != nil {
+				return nil, fmt.Errorf("%w: attribute %q of %q: %v", ErrInvalidEncoding, field.Key, t.Title, err)
+			}
+		}
+		cred.setAttribute(field.Key, value)
+	}
+	return cred, nil
+}
+
+// KeePass 2 XML structures. Only the elements relevant for credentials are
+// mapped; the history of entries is ignored.
+type keePassFile struct {
+	XMLName xml.Name     `xml:"KeePassFile"`
+	Meta    keePassMeta  `xml:"Meta"`
+	Root    keePassGroup `xml:"Root>Group"`
+}
+
+type keePassMeta struct {
+	Generator string `xml:"Generator"`
+}
+
+type keePassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+type keePassEntry struct {
+	Strings []keePassString `xml:"String"`
+	Times   *keePassTimes   `xml:"Times,omitempty"`
+}
+
+type keePassString struct {
+	Key   string       `xml:"Key"`
+	Value keePassValue `xml:"Value"`
+}
+
+type keePassValue struct {
+	Value           string `xml:",chardata"`
+	ProtectInMemory bool   `xml:"ProtectInMemory,attr,omitempty"`
+}
+
+type keePassTimes struct {
+	LastModificationTime string `xml:"LastModificationTime,omitempty"`
+}
+
+const keePassGroupName = "Windows Credentials"
+
+// WriteKeePassXML writes the given credentials in the XML export format of
+// KeePass 2. Passwords and the values of sensitive attributes are marked as
+// protected, but are stored in clear text, as in the export files of KeePass.
+func WriteKeePassXML(w io.Writer, creds []*Credential) error {
+	file := keePassFile{Meta: keePassMeta{Generator: "wincred"}, Root: keePassGroup{Name: keePassGroupName}}
+	for _, cred := range creds {
+		entry := newPasswordEntry(cred)
+		var out keePassEntry
+		add := func(key, value string, protected bool) {
+			out.Strings = append(out.Strings, keePassString{Key: key, Value: keePassValue{Value: value, ProtectInMemory: protected}})
+		}
+		add("Title", entry.Title, false)
+		add("UserName", entry.UserName, false)
+		add("Password", entry.Password, true)
+		add("URL", entry.URL, false)
+		add("Notes", entry.Notes, false)
+		for _, field := range entry.Fields {
+			add(field.Key, field.Value, field.Protected)
+		}
+		if !entry.LastModified.IsZero() {
+			out.Times = &keePassTimes{LastModificationTime: entry.LastModified.UTC().Format(time.RFC3339)}
+		}
+		file.Root.Entries = append(file.Root.Entries, out)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(file); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadKeePassXML reads the entries of all groups of a KeePass 2 XML export
+// and maps them to credentials. The title of an entry becomes the target name.
+func ReadKeePassXML(r io.Reader) ([]*Credential, error) {
+	var file keePassFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	var creds []*Credential
+	var walk func(group *keePassGroup) error
+	walk = func(group *keePassGroup) error {
+		for _, in := range group.Entries {
+			var entry passwordEntry
+			for _, s := range in.Strings {
+				switch s.Key {
+				case "Title":
+					entry.Title = s.Value.Value
+				case "UserName":
+					entry.UserName = s.Value.Value
+				case "Password":
+					entry.Password = s.Value.Value
+				case "URL":
+					entry.URL = s.Value.Value
+				case "Notes":
+					entry.Notes = s.Value.Value
+				default:
+					entry.Fields = append(entry.Fields, passwordField{Key: s.Key, Value: s.Value.Value, Protected: s.Value.ProtectInMemory})
+				}
+			}
+			if in.Times != nil && in.Times.LastModificationTime != "" {
+				entry.LastModified, _ = time.Parse(time.RFC3339, in.Times.LastModificationTime)
+			}
+			if entry.Title == "" && entry.URL == "" {
+				continue
+			}
+			cred, err := entry.credential()
+			if err != nil {
+				return err
+			}
+			creds = append(creds, cred)
+		}
+		for i := range group.Groups {
+			if err := walk(&group.Groups[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(&file.Root); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Bitwarden unencrypted JSON structures.
+type bitwardenExport struct {
+	Encrypted bool              `json:"encrypted"`
+	Folders   []json.RawMessage `json:"folders"`
+	Items     []bitwardenItem   `json:"items"`
+}
+
+const (
+	bitwardenItemLogin   = 1
+	bitwardenFieldText   = 0
+	bitwardenFieldHidden = 1
+)
+
+type bitwardenItem struct {
+	Type         int              `json:"type"`
+	Name         string           `json:"name"`
+	Notes        *string          `json:"notes"`
+	Favorite     bool             `json:"favorite"`
+	Login        *bitwardenLogin  `json:"login,omitempty"`
+	Fields       []bitwardenField `json:"fields,omitempty"`
+	RevisionDate *time.Time       `json:"revisionDate,omitempty"`
+}
+
+type bitwardenLogin struct {
+	Username *string        `json:"username"`
+	Password *string        `json:"password"`
+	URIs     []bitwardenURI `json:"uris,omitempty"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+type bitwardenField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  int    `json:"type"`
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// WriteBitwardenJSON writes the given credentials as login items in the
+// unencrypted JSON export format of Bitwarden. Sensitive attributes become
+// hidden custom fields.
+func WriteBitwardenJSON(w io.Writer, creds []*Credential) error {
+	export := bitwardenExport{Folders: []json.RawMessage{}, Items: []bitwardenItem{}}
+	for _, cred := range creds {
+		entry := newPasswordEntry(cred)
+		item := bitwardenItem{
+			Type:  bitwardenItemLogin,
+			Name:  entry.Title,
+			Notes: optionalString(entry.Notes),
+			Login: &bitwardenLogin{
+				Username: optionalString(entry.UserName),
+				Password: optionalString(entry.Password),
+			},
+		}
+		if entry.URL != "" {
+			item.Login.URIs = []bitwardenURI{{URI: entry.URL}}
+		}
+		for _, field := range entry.Fields {
+			typ := bitwardenFieldText
+			if field.Protected {
+				typ = bitwardenFieldHidden
+			}
+			item.Fields = append(item.Fields, bitwardenField{Name: field.Key, Value: field.Value, Type: typ})
+		}
+		if !entry.LastModified.IsZero() {
+			revision := entry.LastModified.UTC()
+			item.RevisionDate = &revision
+		}
+		export.Items = append(export.Items, item)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+// ReadBitwardenJSON reads the login items of an unencrypted Bitwarden JSON
+// export and maps them to credentials. The item name becomes the target name.
+func ReadBitwardenJSON(r io.Reader) ([]*Credential, error) {
+	var export bitwardenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	if export.Encrypted {
+		return nil, fmt.Errorf("%w: encrypted Bitwarden exports are not supported", ErrUnsupportedFormat)
+	}
+	var creds []*Credential
+	for _, item := range export.Items {
+		if item.Type != bitwardenItemLogin || item.Login == nil {
+			continue
+		}
+		entry := passwordEntry{
+			Title:    item.Name,
+			UserName: stringValue(item.Login.Username),
+			Password: stringValue(item.Login.Password),
+			Notes:    stringValue(item.Notes),
+		}
+		if len(item.Login.URIs) > 0 {
+			entry.URL = item.Login.URIs[0].URI
+		}
+		for _, field := range item.Fields {
+			entry.Fields = append(entry.Fields, passwordField{Key: field.Name, Value: field.Value, Protected: field.Type == bitwardenFieldHidden})
+		}
+		if item.RevisionDate != nil {
+			entry.LastModified = *item.RevisionDate
+		}
+		cred, err := entry.credential()
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+	return creds, nil
+}
+
+var csvHeader = []string{"target", "username", "password", "comment", "attributes"}
+
+// WriteCSV writes the given credentials as CSV with the columns target,
+// username, password, comment and attributes. The attributes are encoded like
+// URL query parameters, e.g. "key1=value1&key2=value2".
+func WriteCSV(w io.Writer, creds []*Credential) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, cred := range creds {
+		entry := newPasswordEntry(cred)
+		var attrs []string
+		for _, field := range entry.Fields {
+			attrs = append(attrs, url.QueryEscape(field.Key)+"="+url.QueryEscape(field.Value))
+		}
+		if err := out.Write([]string{entry.Title, entry.UserName, entry.Password, entry.Notes, strings.Join(attrs, "&")}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ReadCSV reads credentials in the CSV format written by WriteCSV.
+// The columns are identified by the header row; unknown columns are ignored.
+func ReadCSV(r io.Reader) ([]*Credential, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	records, err := in.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["target"]; !ok {
+		return nil, fmt.Errorf("%w: missing target column", ErrInvalidEncoding)
+	}
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	var creds []*Credential
+	for _, record := range records[1:] {
+		entry := passwordEntry{
+			Title:    column(record, "target"),
+			UserName: column(record, "username"),
+			Password: column(record, "password"),
+			Notes:    column(record, "comment"),
+		}
+		if entry.Title == "" {
+			continue
+		}
+		if attrs := column(record, "attributes"); attrs != "" {
+			for _, pair := range strings.Split(attrs, "&") {
+				kv := strings.SplitN(pair, "=", 2)
+				key, err := url.QueryUnescape(kv[0])
+				if err != nil {
+					return nil, fmt.Errorf("%w: attributes of %q: %v", ErrInvalidEncoding, entry.Title, err)
+				}
+				var value string
+				if len(kv) == 2 {
+					if value, err = url.QueryUnescape(kv[1]); err != nil {
+						return nil, fmt.Errorf("%w: attributes of %q: %v", ErrInvalidEncoding, entry.Title, err)
+					}
+				}
+				entry.Fields = append(entry.Fields, passwordField{Key: key, Value: value})
+			}
+		}
+		cred, err := entry.credential()
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+	return creds, nil
+}
+
+// WritePasswords writes the given credentials in the given password manager format.
+func WritePasswords(w io.Writer, creds []*Credential, format PasswordFormat) error {
+	switch format {
+	case FormatKeePassXML:
+		return WriteKeePassXML(w, creds)
+	case FormatBitwardenJSON:
+		return WriteBitwardenJSON(w, creds)
+	case FormatCSV:
+		return WriteCSV(w, creds)
+	}
+	return ErrUnsupportedFormat
+}
+
+// ReadPasswords reads credentials in the given password manager format.
+func ReadPasswords(r io.Reader, format PasswordFormat) ([]*Credential, error) {
+	switch format {
+	case FormatKeePassXML:
+		return ReadKeePassXML(r)
+	case FormatBitwardenJSON:
+		return ReadBitwardenJSON(r)
+	case FormatCSV:
+		return ReadCSV(r)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// ExportPasswords writes all credentials matching the given filter (see
+// FilteredList) in the given password manager format, sorted by target name.
+// An empty filter exports all credentials.
+// The output contains the secrets in clear text.
+func ExportPasswords(w io.Writer, filter string, format PasswordFormat) error {
+	creds, err := listCredentials(filter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, cred := range creds {
+			cred.Wipe()
+		}
+	}()
+	sort.Slice(creds, func(i, j int) bool { return creds[i].TargetName < creds[j].TargetName })
+	return WritePasswords(w, creds, format)
+}
+
+// ImportPasswords reads credentials in the given password manager format and
+// stores them in Windows credential manager.
+func ImportPasswords(r io.Reader, format PasswordFormat, opts ImportOptions) (*ImportReport, error) {
+	creds, err := ReadPasswords(r, format)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, cred := range creds {
+			cred.Wipe()
+		}
+	}()
+	return importCredentials(creds, opts), nil
+}
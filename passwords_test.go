@@ -0,0 +1,207 @@
+package wincred
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fixturePasswordCredentials() []*Credential {
+	return []*Credential{
+		{
+			TargetName:     "git:https://github.com",
+			UserName:       "alice",
+			Comment:        "GitHub",
+			CredentialBlob: encodeUTF16LE("s3cr3t"),
+			LastWritten:    time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC),
+			Attributes: []CredentialAttribute{
+				{Keyword: "Token", Value: []byte("token value")},
+				{Keyword: "Raw", Value: []byte{0xff, 0x00}},
+			},
+			Persist: PersistEnterprise,
+			Type:    TypeGeneric,
+		},
+		{
+			TargetName:     "myGoApplication",
+			CredentialBlob: []byte("my secret"),
+			Persist:        PersistLocalMachine,
+			Type:           TypeGeneric,
+		},
+	}
+}
+
+func assertPasswordRoundTrip(t *testing.T, expected, actual []*Credential, withTimes bool) {
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.Equal(t, expected[i].TargetName, actual[i].TargetName)
+		assert.Equal(t, expected[i].UserName, actual[i].UserName)
+		assert.Equal(t, expected[i].Comment, actual[i].Comment)
+		assert.Equal(t, expected[i].CredentialBlob, actual[i].CredentialBlob)
+		assert.Equal(t, expected[i].Attributes, actual[i].Attributes)
+		assert.Equal(t, expected[i].Persist, actual[i].Persist)
+		assert.Equal(t, expected[i].Type, actual[i].Type)
+		if withTimes {
+			assert.True(t, expected[i].LastWritten.Equal(actual[i].LastWritten))
+		}
+	}
+}
+
+func TestKeePassXML(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	creds := fixturePasswordCredentials()
+	var buf bytes.Buffer
+	assert.Nil(t, WriteKeePassXML(&buf, creds))
+	out := buf.String()
+	assert.Contains(t, out, "<KeePassFile>")
+	assert.Contains(t, out, "<Key>Password</Key>")
+	assert.Contains(t, out, `<Value ProtectInMemory="true">s3cr3t</Value>`)
+	assert.Contains(t, out, `<Value>https://github.com</Value>`)
+	assert.Contains(t, out, `<Value ProtectInMemory="true">token value</Value>`)
+
+	res, err := ReadKeePassXML(&buf)
+	assert.Nil(t, err)
+	assertPasswordRoundTrip(t, creds, res, true)
+}
+
+func TestReadKeePassXML_Groups(t *testing.T) {
+	input := `<?xml version="1.0" encoding="utf-8"?>
+<KeePassFile>
+	<Root>
+		<Group>
+			<Name>Root</Name>
+			<Group>
+				<Name>Sub</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>nested</Value></String>
+					<String><Key>Password</Key><Value ProtectInMemory="True">pw</Value></String>
+					<History>
+						<Entry><String><Key>Title</Key><Value>old</Value></String></Entry>
+					</History>
+				</Entry>
+			</Group>
+			<Entry>
+				<String><Key>URL</Key><Value>https://example.com</Value></String>
+			</Entry>
+		</Group>
+	</Root>
+</KeePassFile>`
+	res, err := ReadKeePassXML(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, "https://example.com", res[0].TargetName)
+	assert.Equal(t, "nested", res[1].TargetName)
+	assert.Equal(t, []byte("pw"), res[1].CredentialBlob)
+}
+
+func TestBitwardenJSON(t *testing.T) {
+	MarkSensitiveAttribute("Token")
+	defer UnmarkSensitiveAttribute("Token")
+	creds := fixturePasswordCredentials()
+	var buf bytes.Buffer
+	assert.Nil(t, WriteBitwardenJSON(&buf, creds))
+	out := buf.String()
+	assert.Contains(t, out, `"encrypted": false`)
+	assert.Contains(t, out, `"uri": "https://github.com"`)
+	assert.Contains(t, out, `"password": "s3cr3t"`)
+
+	res, err := ReadBitwardenJSON(&buf)
+	assert.Nil(t, err)
+	assertPasswordRoundTrip(t, creds, res, true)
+}
+
+func TestReadBitwardenJSON(t *testing.T) {
+	input := `{"encrypted": false, "items": [
+		{"type": 2, "name": "note", "secureNote": {"type": 0}},
+		{"type": 1, "name": "site", "notes": null, "login": {"username": "bob", "password": "pw", "uris": [{"match": null, "uri": "https://example.com"}]}, "fields": [{"name": "pin", "value": "1234", "type": 1}]}
+	]}`
+	res, err := ReadBitwardenJSON(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, "site", res[0].TargetName)
+	assert.Equal(t, "bob", res[0].UserName)
+	assert.Equal(t, []byte("pw"), res[0].CredentialBlob)
+	assert.Equal(t, []CredentialAttribute{{Keyword: "pin", Value: []byte("1234")}}, res[0].Attributes)
+
+	_, err = ReadBitwardenJSON(strings.NewReader(`{"encrypted": true}`))
+	assert.True(t, errors.Is(err, ErrUnsupportedFormat))
+}
+
+func TestCSV(t *testing.T) {
+	creds := fixturePasswordCredentials()
+	var buf bytes.Buffer
+	assert.Nil(t, WriteCSV(&buf, creds))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "target,username,password,comment,attributes", lines[0])
+	assert.Equal(t, "myGoApplication,,my secret,,", lines[2])
+
+	res, err := ReadCSV(&buf)
+	assert.Nil(t, err)
+	assertPasswordRoundTrip(t, creds, res, false)
+}
+
+func TestReadCSV(t *testing.T) {
+	res, err := ReadCSV(strings.NewReader("Password,Target,Extra\npw,site,x\n,,\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, "site", res[0].TargetName)
+	assert.Equal(t, []byte("pw"), res[0].CredentialBlob)
+
+	_, err = ReadCSV(strings.NewReader("user,password\n"))
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+}
+
+func TestPasswordEntry_InvalidEncoding(t *testing.T) {
+	entry := passwordEntry{Title: "x", Fields: []passwordField{{Key: passwordFieldEncoding, Value: "rot13"}}}
+	_, err := entry.credential()
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+}
+
+func TestPasswords_UnsupportedFormat(t *testing.T) {
+	assert.True(t, errors.Is(WritePasswords(&bytes.Buffer{}, nil, 0), ErrUnsupportedFormat))
+	_, err := ReadPasswords(strings.NewReader(""), 0)
+	assert.True(t, errors.Is(err, ErrUnsupportedFormat))
+}
+
+func TestTargetURL(t *testing.T) {
+	assert.Equal(t, "https://github.com", targetURL("git:https://github.com"))
+	assert.Equal(t, "http://example.com/x", targetURL("http://example.com/x"))
+	assert.Equal(t, "", targetURL("myGoApplication"))
+	assert.Equal(t, "", targetURL("TERMSRV/host"))
+}
+
+func TestPasswords_SealedRoundTrip(t *testing.T) {
+	enc := NewEncryptor(fixtureKeys(), "Token")
+	for _, format := range []PasswordFormat{FormatKeePassXML, FormatBitwardenJSON, FormatCSV} {
+		cred := &Credential{
+			TargetName:     "sealed",
+			CredentialBlob: []byte("my secret"),
+			Attributes:     []CredentialAttribute{{Keyword: "Token", Value: []byte("abc")}},
+			Persist:        PersistLocalMachine,
+			Type:           TypeGeneric,
+		}
+		assert.Nil(t, enc.Seal(cred))
+		var buf bytes.Buffer
+		assert.Nil(t, WritePasswords(&buf, []*Credential{cred}, format))
+		res, err := ReadPasswords(&buf, format)
+		assert.Nil(t, err)
+		assertPasswordRoundTrip(t, []*Credential{cred}, res, false)
+		assert.True(t, IsSealed(res[0]))
+		assert.Nil(t, enc.Open(res[0]))
+		assert.Equal(t, []byte("my secret"), res[0].CredentialBlob)
+		assert.Equal(t, []CredentialAttribute{{Keyword: "Token", Value: []byte("abc")}}, res[0].Attributes)
+	}
+}
+
+func TestPasswords_UTF16Terminator(t *testing.T) {
+	cred := &Credential{TargetName: "wide", CredentialBlob: append(encodeUTF16LE("secret"), 0, 0), Persist: PersistLocalMachine, Type: TypeGeneric}
+	var buf bytes.Buffer
+	assert.Nil(t, WritePasswords(&buf, []*Credential{cred}, FormatCSV))
+	res, err := ReadPasswords(&buf, FormatCSV)
+	assert.Nil(t, err)
+	assertPasswordRoundTrip(t, []*Credential{cred}, res, false)
+}
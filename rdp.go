@@ -0,0 +1,119 @@
+package wincred
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// rdpTargetPrefix is the target name prefix of the domain credentials used by
+// the remote desktop client.
+const rdpTargetPrefix = "TERMSRV/"
+
+// RDPTarget returns the target name of the remote desktop credential for the
+// given host.
+func RDPTarget(host string) string {
+	return rdpTargetPrefix + host
+}
+
+// NewRDPCredential creates a new remote desktop credential for the given host,
+// user and password. The user name should be given as "DOMAIN\user" or UPN.
+// The credential object is NOT yet persisted to the Windows credential vault.
+func NewRDPCredential(host, userName, password string) *DomainPassword {
+	cred := NewDomainPassword(RDPTarget(host))
+	cred.UserName = userName
+	cred.SetPassword(password)
+	return cred
+}
+
+// GetRDPCredential fetches the remote desktop credential for the given host
+// from Windows credential manager.
+func GetRDPCredential(host string) (*DomainPassword, error) {
+	return GetDomainPassword(RDPTarget(host))
+}
+
+// DeleteRDPCredential removes the remote desktop credential for the given host
+// from Windows credential manager.
+func DeleteRDPCredential(host string) error {
+	return NewDomainPassword(RDPTarget(host)).Delete()
+}
+
+// ListRDPCredentials retrieves all remote desktop credentials.
+func ListRDPCredentials() ([]*DomainPassword, error) {
+	creds, err := FilteredList(rdpTargetPrefix + "*")
+	if err != nil {
+		return nil, err
+	}
+	var result []*DomainPassword
+	for _, cred := range creds {
+		if cred.Type == TypeDomainPassword {
+			result = append(result, &DomainPassword{Credential: *cred})
+		}
+	}
+	return result, nil
+}
+
+// RDPHost returns the host of a remote desktop credential target name and
+// whether the target name has the "TERMSRV/" prefix.
+func RDPHost(targetName string) (string, bool) {
+	if !hasPrefixFold(targetName, rdpTargetPrefix) {
+		return "", false
+	}
+	return targetName[len(rdpTargetPrefix):], true
+}
+
+// FormatDownLevelLogonName returns the user name in the "DOMAIN\user" form.
+func FormatDownLevelLogonName(domain, user string) string {
+	return domain + `\` + user
+}
+
+// FormatUPN returns the user principal name "user@domain".
+func FormatUPN(user, domain string) string {
+	return user + "@" + domain
+}
+
+// SplitDownLevelLogonName splits a user name of the form "DOMAIN\user".
+func SplitDownLevelLogonName(userName string) (domain, user string, ok bool) {
+	name, err := ParseUserName(userName)
+	if err != nil || name.Format != UserNameDownLevel {
+		return "", "", false
+	}
+	return name.Domain, name.User, true
+}
+
+// SplitUPN splits a user principal name of the form "user@domain".
+func SplitUPN(userName string) (user, domain string, ok bool) {
+	name, err := ParseUserName(userName)
+	if err != nil || name.Format != UserNameUPN {
+		return "", "", false
+	}
+	return name.User, name.Domain, true
+}
+
+// WriteRDPFile writes a remote desktop connection file for the host and user
+// of the given remote desktop credential, so that the client uses the stored
+// credential instead of prompting for one. The file is encoded as UTF-16
+// little-endian with byte order mark, like the files saved by the client.
+func WriteRDPFile(w io.Writer, cred *DomainPassword) error {
+	host, ok := RDPHost(cred.TargetName)
+	if !ok || host == "" {
+		return fmt.Errorf("%q is not a remote desktop target", cred.TargetName)
+	}
+	if strings.ContainsAny(host+cred.UserName, "\r\n") {
+		return fmt.Errorf("%w: line break in host or user name", ErrInvalidEncoding)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "full address:s:%s\r\n", host)
+	if cred.UserName != "" {
+		fmt.Fprintf(&buf, "username:s:%s\r\n", cred.UserName)
+	}
+	buf.WriteString("prompt for credentials:i:0\r\n")
+	buf.WriteString("promptcredentialonce:i:1\r\n")
+	buf.WriteString("authentication level:i:2\r\n")
+	if _, err := w.Write([]byte{0xff, 0xfe}); err != nil {
+		return err
+	}
+	_, err := w.Write(encodeUTF16LE(buf.String()))
+	return err
+}
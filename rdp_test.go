@@ -0,0 +1,54 @@
+package wincred
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRDPCredential(t *testing.T) {
+	cred := NewRDPCredential("jump01.contoso.com", `CONTOSO\alice`, "pa55")
+	assert.Equal(t, "TERMSRV/jump01.contoso.com", cred.TargetName)
+	assert.Equal(t, TypeDomainPassword, cred.Type)
+	assert.Equal(t, `CONTOSO\alice`, cred.UserName)
+
+	host, ok := RDPHost(cred.TargetName)
+	assert.True(t, ok)
+	assert.Equal(t, "jump01.contoso.com", host)
+	_, ok = RDPHost("jump01.contoso.com")
+	assert.False(t, ok)
+}
+
+func TestUserNameHelpers(t *testing.T) {
+	assert.Equal(t, `CONTOSO\alice`, FormatDownLevelLogonName("CONTOSO", "alice"))
+	assert.Equal(t, "alice@contoso.com", FormatUPN("alice", "contoso.com"))
+
+	domain, user, ok := SplitDownLevelLogonName(`CONTOSO\alice`)
+	assert.True(t, ok)
+	assert.Equal(t, "CONTOSO", domain)
+	assert.Equal(t, "alice", user)
+	_, _, ok = SplitDownLevelLogonName("alice@contoso.com")
+	assert.False(t, ok)
+
+	user, domain, ok = SplitUPN("alice@contoso.com")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", user)
+	assert.Equal(t, "contoso.com", domain)
+	_, _, ok = SplitUPN(`CONTOSO\alice`)
+	assert.False(t, ok)
+}
+
+func TestWriteRDPFile(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteRDPFile(&buf, NewRDPCredential("jump01", "alice@contoso.com", "pa55"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xff, 0xfe}, buf.Bytes()[:2])
+	text, ok := decodeUTF16LE(buf.Bytes()[2:])
+	assert.True(t, ok)
+	assert.Contains(t, text, "full address:s:jump01\r\n")
+	assert.Contains(t, text, "username:s:alice@contoso.com\r\n")
+	assert.NotContains(t, text, "pa55")
+
+	assert.NotNil(t, WriteRDPFile(&buf, NewDomainPassword("jump01")))
+}
@@ -0,0 +1,102 @@
+package wincred
+
+import "errors"
+
+// ErrLockNotSupported is returned by Secret.Lock on platforms that do not
+// support locking memory pages.
+var ErrLockNotSupported = errors.New("memory locking not supported")
+
+// Secret is a byte buffer for sensitive data like passwords or tokens.
+// In contrast to ordinary Go slices or strings, its content can be
+// explicitly overwritten with Wipe once it is not needed anymore.
+// Optionally, the buffer can be locked into physical memory to prevent it from
+// being paged out to disk.
+type Secret struct {
+	data   []byte
+	locked bool
+}
+
+// NewSecret allocates a new zeroed secret buffer of the given size.
+func NewSecret(size int) *Secret {
+	return &Secret{data: make([]byte, size)}
+}
+
+// SecretFromBytes copies the given bytes to a new secret buffer and wipes the
+// source slice afterwards.
+func SecretFromBytes(b []byte) *Secret {
+	result := NewSecret(len(b))
+	copy(result.data, b)
+	wipeBytes(b)
+	return result
+}
+
+// Bytes returns the content of the secret buffer.
+// The returned slice shares memory with the buffer and is wiped together with it.
+func (s *Secret) Bytes() []byte {
+	return s.data
+}
+
+// Len returns the size of the secret buffer.
+func (s *Secret) Len() int {
+	return len(s.data)
+}
+
+// Lock locks the memory pages of the secret buffer, so that they are not paged
+// out to disk. The pages are unlocked again by Wipe.
+// It returns ErrLockNotSupported on platforms without support for memory locking.
+func (s *Secret) Lock() error {
+	if s.locked || len(s.data) == 0 {
+		return nil
+	}
+	if err := memLock(s.data); err != nil {
+		return err
+	}
+	s.locked = true
+	return nil
+}
+
+// Locked reports whether the memory pages of the secret buffer are locked.
+func (s *Secret) Locked() bool {
+	return s.locked
+}
+
+// Wipe overwrites the content of the secret buffer with zeros, unlocks its
+// memory pages and releases it.
+func (s *Secret) Wipe() {
+	wipeBytes(s.data)
+	if s.locked {
+		memUnlock(s.data)
+		s.locked = false
+	}
+	s.data = nil
+}
+
+// String implements fmt.Stringer without revealing the secret.
+func (s *Secret) String() string {
+	return "[REDACTED]"
+}
+
+// Wipe overwrites the credential blob and all attribute values of the
+// credential with zeros.
+// It should be called as soon as a credential that was read from the
+// credential store is not needed anymore.
+func (t *Credential) Wipe() {
+	wipeBytes(t.CredentialBlob)
+	for i := range t.Attributes {
+		wipeBytes(t.Attributes[i].Value)
+	}
+}
+
+// wipeBytes overwrites the given slice with zeros.
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// wipeUint16 overwrites the given slice with zeros.
+func wipeUint16(s []uint16) {
+	for i := range s {
+		s[i] = 0
+	}
+}
@@ -0,0 +1,52 @@
+package wincred
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret_Wipe(t *testing.T) {
+	s := NewSecret(4)
+	copy(s.Bytes(), "abcd")
+	buf := s.Bytes()
+	s.Wipe()
+	assert.Equal(t, []byte{0, 0, 0, 0}, buf)
+	assert.Equal(t, 0, s.Len())
+}
+
+func TestSecretFromBytes(t *testing.T) {
+	src := []byte("my secret")
+	s := SecretFromBytes(src)
+	assert.Equal(t, []byte("my secret"), s.Bytes())
+	assert.Equal(t, make([]byte, 9), src)
+}
+
+func TestSecret_Lock(t *testing.T) {
+	s := SecretFromBytes([]byte("my secret"))
+	err := s.Lock()
+	if err != nil {
+		t.Skipf("memory locking not available: %v", err)
+	}
+	assert.True(t, s.Locked())
+	s.Wipe()
+	assert.False(t, s.Locked())
+}
+
+func TestSecret_String(t *testing.T) {
+	s := SecretFromBytes([]byte("my secret"))
+	assert.Equal(t, "[REDACTED]", s.String())
+}
+
+func TestCredential_Wipe(t *testing.T) {
+	cred := &Credential{
+		CredentialBlob: []byte{1, 2, 3},
+		Attributes: []CredentialAttribute{
+			{Keyword: "Foo", Value: []byte{4, 5}},
+		},
+	}
+	cred.Wipe()
+	assert.Equal(t, []byte{0, 0, 0}, cred.CredentialBlob)
+	assert.Equal(t, []byte{0, 0}, cred.Attributes[0].Value)
+	assert.Equal(t, "Foo", cred.Attributes[0].Keyword)
+}
@@ -0,0 +1,86 @@
+package wincred
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedType is returned when reading or writing a credential whose
+// type is not supported by this package.
+var ErrUnsupportedType = errors.New("unsupported credential type")
+
+// readCredential fetches the credential with the given name and type from
+// Windows credential manager.
+func readCredential(targetName string, typ CredentialType) (*Credential, error) {
+	switch typ {
+	case TypeGeneric:
+		cred, err := GetGenericCredential(targetName)
+		if err != nil {
+			return nil, err
+		}
+		return &cred.Credential, nil
+	case TypeDomainPassword:
+		cred, err := GetDomainPassword(targetName)
+		if err != nil {
+			return nil, err
+		}
+		return &cred.Credential, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, typ)
+}
+
+// writeCredential persists the given credential to Windows credential manager
+// according to its type.
+func writeCredential(cred *Credential) error {
+	switch cred.Type {
+	case TypeGeneric:
+		return (&GenericCredential{Credential: *cred}).Write()
+	case TypeDomainPassword:
+		return (&DomainPassword{Credential: *cred}).Write()
+	}
+	return fmt.Errorf("%w: %v", ErrUnsupportedType, cred.Type)
+}
+
+// deleteCredential removes the given credential from Windows credential
+// manager according to its type.
+func deleteCredential(cred *Credential) error {
+	switch cred.Type {
+	case TypeGeneric:
+		return (&GenericCredential{Credential: *cred}).Delete()
+	case TypeDomainPassword:
+		return (&DomainPassword{Credential: *cred}).Delete()
+	}
+	return fmt.Errorf("%w: %v", ErrUnsupportedType, cred.Type)
+}
+
+// listCredentials lists the credentials matching the given filter (see
+// FilteredList). An empty filter lists all credentials.
+func listCredentials(filter string) ([]*Credential, error) {
+	if filter == "" {
+		return List()
+	}
+	return FilteredList(filter)
+}
+
+// listPrefix lists the credentials whose target names start with the given
+// prefix, compared case-insensitively like Windows does. Prefixes containing
+// the filter wildcard are matched in Go.
+func listPrefix(prefix string) ([]*Credential, error) {
+	if prefix != "" && !strings.Contains(prefix, "*") {
+		return FilteredList(prefix + "*")
+	}
+	creds, err := List()
+	if err != nil {
+		return nil, err
+	}
+	result := creds[:0]
+	for _, cred := range creds {
+		if hasPrefixFold(cred.TargetName, prefix) {
+			result = append(result, cred)
+		} else {
+			cred.Wipe()
+		}
+	}
+	return result, nil
+}
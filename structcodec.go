@@ -0,0 +1,328 @@
+package wincred
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidStructTag is returned by Marshal and Unmarshal for malformed
+	// `wincred` struct tags or tags applied to unsupported field types.
+	ErrInvalidStructTag = errors.New("invalid wincred struct tag")
+
+	// ErrBlobTooLarge is returned for credential blobs exceeding
+	// MaxCredentialBlobSize bytes.
+	ErrBlobTooLarge = errors.New("credential blob too large")
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	bytesType           = reflect.TypeOf([]byte(nil))
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// structField is a field of a struct with a `wincred` tag.
+type structField struct {
+	// Kind is one of "target", "username", "comment", "blob" or "attr".
+	Kind    string
+	Keyword string
+	UTF16   bool
+	JSON    bool
+	Index   []int
+}
+
+// structFields returns the tagged fields of the given struct type. Untagged
+// fields of struct type are searched recursively.
+func structFields(typ reflect.Type, index []int) ([]structField, error) {
+	var fields []structField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		tag, tagged := field.Tag.Lookup("wincred")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		if !tagged {
+			if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+				nested, err := structFields(field.Type, fieldIndex)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, nested...)
+			}
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		result := structField{Kind: parts[0], Index: fieldIndex}
+		if strings.HasPrefix(result.Kind, "attr=") {
+			result.Kind, result.Keyword = "attr", strings.TrimPrefix(result.Kind, "attr=")
+		}
+		for _, option := range parts[1:] {
+			switch option {
+			case "utf16":
+				result.UTF16 = true
+			case "json":
+				result.JSON = true
+			default:
+				return nil, fmt.Errorf("%w: unknown option %q of field %s", ErrInvalidStructTag, option, field.Name)
+			}
+		}
+		switch result.Kind {
+		case "target", "username", "comment":
+			if field.Type.Kind() != reflect.String || result.JSON || result.UTF16 {
+				return nil, fmt.Errorf("%w: field %s must be a plain string", ErrInvalidStructTag, field.Name)
+			}
+		case "blob":
+		case "attr":
+			if result.Keyword == "" {
+				return nil, fmt.Errorf("%w: empty attribute keyword of field %s", ErrInvalidStructTag, field.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %q of field %s", ErrInvalidStructTag, tag, field.Name)
+		}
+		fields = append(fields, result)
+	}
+	return fields, nil
+}
+
+// structValue returns the struct value v points to.
+func structValue(v interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%w: expected struct, got %T", ErrInvalidStructTag, v)
+	}
+	return value, nil
+}
+
+// Marshal creates a generic credential from the fields of the given struct,
+// as defined by their `wincred` struct tags:
+//
+//	Target   string `wincred:"target"`
+//	User     string `wincred:"username"`
+//	Comment  string `wincred:"comment"`
+//	Password string `wincred:"blob"`
+//	Host     string `wincred:"attr=host"`
+//	Port     int    `wincred:"attr=port"`
+//
+// Strings are stored UTF-8 encoded, or UTF-16 encoded with the "utf16" option.
+// Byte slices are stored as is, booleans, integers and floats in their 1 or 8
+// byte little-endian representation and times as FILETIME, with the zero time
+// stored as FILETIME 0 so that it is zero after unmarshaling.
+// Types implementing encoding.TextMarshaler are stored as text. Other types,
+// and all fields with the "json" option, are stored JSON encoded.
+// Untagged fields of struct type are marshaled recursively.
+// The size limits of attributes and credential blobs are enforced.
+func Marshal(v interface{}) (*Credential, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := structFields(value.Type(), nil)
+	if err != nil {
+		return nil, err
+	}
+	cred := &NewGenericCredential("").Credential
+	for _, field := range fields {
+		fieldValue := value.FieldByIndex(field.Index)
+		switch field.Kind {
+		case "target":
+			cred.TargetName = fieldValue.String()
+		case "username":
+			cred.UserName = fieldValue.String()
+		case "comment":
+			cred.Comment = fieldValue.String()
+		case "blob":
+			data, err := encodeFieldValue(fieldValue, field)
+			if err != nil {
+				return nil, err
+			}
+			if len(data) > MaxCredentialBlobSize {
+				return nil, fmt.Errorf("%w: %d bytes", ErrBlobTooLarge, len(data))
+			}
+			cred.CredentialBlob = data
+		case "attr":
+			data, err := encodeFieldValue(fieldValue, field)
+			if err != nil {
+				return nil, err
+			}
+			if err := cred.SetAttribute(field.Keyword, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cred, nil
+}
+
+// Unmarshal stores the content of the credential in the fields of the struct
+// v points to, as defined by their `wincred` struct tags (see Marshal).
+// Fields of attributes the credential does not have are left unchanged.
+func Unmarshal(cred *Credential, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("%w: expected pointer to struct, got %T", ErrInvalidStructTag, v)
+	}
+	value, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, err := structFields(value.Type(), nil)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldValue := value.FieldByIndex(field.Index)
+		switch field.Kind {
+		case "target":
+			fieldValue.SetString(cred.TargetName)
+		case "username":
+			fieldValue.SetString(cred.UserName)
+		case "comment":
+			fieldValue.SetString(cred.Comment)
+		case "blob":
+			if err := decodeFieldValue(cred.CredentialBlob, fieldValue, field); err != nil {
+				return fmt.Errorf("credential blob: %w", err)
+			}
+		case "attr":
+			data, ok := cred.GetAttribute(field.Keyword)
+			if !ok {
+				continue
+			}
+			if err := decodeFieldValue(data, fieldValue, field); err != nil {
+				return fmt.Errorf("attribute %q: %w", field.Keyword, err)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeFieldValue encodes a field value as described at Marshal.
+func encodeFieldValue(value reflect.Value, field structField) ([]byte, error) {
+	if field.JSON {
+		return json.Marshal(value.Interface())
+	}
+	if value.Type() == timeType {
+		filetime, err := toFiletime(value.Interface().(time.Time))
+		if err != nil {
+			return nil, err
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(filetime))
+		return buf[:], nil
+	}
+	if value.Type().Implements(textMarshalerType) {
+		return value.Interface().(encoding.TextMarshaler).MarshalText()
+	}
+	var buf [8]byte
+	switch value.Kind() {
+	case reflect.String:
+		if field.UTF16 {
+			return encodeUTF16LE(value.String()), nil
+		}
+		return []byte(value.String()), nil
+	case reflect.Bool:
+		if value.Bool() {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		binary.LittleEndian.PutUint64(buf[:], uint64(value.Int()))
+		return buf[:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		binary.LittleEndian.PutUint64(buf[:], value.Uint())
+		return buf[:], nil
+	case reflect.Float32, reflect.Float64:
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(value.Float()))
+		return buf[:], nil
+	case reflect.Slice:
+		if value.Type() == bytesType {
+			return append([]byte{}, value.Bytes()...), nil
+		}
+	}
+	return json.Marshal(value.Interface())
+}
+
+// decodeFieldValue decodes data encoded by encodeFieldValue into the field value.
+func decodeFieldValue(data []byte, value reflect.Value, field structField) error {
+	if field.JSON {
+		return json.Unmarshal(data, value.Addr().Interface())
+	}
+	if value.Type() == timeType {
+		if len(data) != 8 {
+			return fmt.Errorf("%w: not a FILETIME", ErrInvalidEncoding)
+		}
+		value.Set(reflect.ValueOf(fromFiletime(int64(binary.LittleEndian.Uint64(data)))))
+		return nil
+	}
+	if value.Addr().Type().Implements(textUnmarshalerType) {
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(data)
+	}
+	fixed := func() (uint64, error) {
+		if len(data) != 8 {
+			return 0, fmt.Errorf("%w: expected 8 bytes, got %d", ErrInvalidEncoding, len(data))
+		}
+		return binary.LittleEndian.Uint64(data), nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		if !field.UTF16 {
+			value.SetString(string(data))
+			return nil
+		}
+		s, ok := decodeUTF16LE(data)
+		if !ok {
+			return fmt.Errorf("%w: not UTF-16", ErrInvalidEncoding)
+		}
+		value.SetString(s)
+		return nil
+	case reflect.Bool:
+		if len(data) != 1 || data[0] > 1 {
+			return fmt.Errorf("%w: not a boolean", ErrInvalidEncoding)
+		}
+		value.SetBool(data[0] == 1)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := fixed()
+		if err != nil {
+			return err
+		}
+		if value.OverflowInt(int64(n)) {
+			return fmt.Errorf("%w: %d overflows %s", ErrInvalidEncoding, int64(n), value.Type())
+		}
+		value.SetInt(int64(n))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := fixed()
+		if err != nil {
+			return err
+		}
+		if value.OverflowUint(n) {
+			return fmt.Errorf("%w: %d overflows %s", ErrInvalidEncoding, n, value.Type())
+		}
+		value.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, err := fixed()
+		if err != nil {
+			return err
+		}
+		value.SetFloat(math.Float64frombits(n))
+		return nil
+	case reflect.Slice:
+		if value.Type() == bytesType {
+			value.SetBytes(append([]byte{}, data...))
+			return nil
+		}
+	}
+	return json.Unmarshal(data, value.Addr().Interface())
+}
@@ -0,0 +1,120 @@
+package wincred
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixtureTLS struct {
+	Insecure bool     `wincred:"attr=insecure"`
+	CAs      []string `wincred:"attr=cas"`
+}
+
+type fixtureConnection struct {
+	Target   string            `wincred:"target"`
+	User     string            `wincred:"username"`
+	Comment  string            `wincred:"comment"`
+	Password string            `wincred:"blob,utf16"`
+	Host     string            `wincred:"attr=host"`
+	Port     uint16            `wincred:"attr=port"`
+	Timeout  time.Duration     `wincred:"attr=timeout"`
+	Rotated  time.Time         `wincred:"attr=rotated"`
+	Address  net.IP            `wincred:"attr=address"`
+	Labels   map[string]string `wincred:"attr=labels"`
+	Options  fixtureTLS        `wincred:"attr=options,json"`
+	TLS      fixtureTLS
+	Ignored  string `wincred:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	in := fixtureConnection{
+		Target:   "db",
+		User:     "admin",
+		Comment:  "primary",
+		Password: "pässword",
+		Host:     "db.example.com",
+		Port:     5432,
+		Timeout:  3 * time.Second,
+		Rotated:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Address:  net.ParseIP("10.0.0.1"),
+		Labels:   map[string]string{"env": "prod"},
+		Options:  fixtureTLS{CAs: []string{"root"}},
+		TLS:      fixtureTLS{Insecure: true, CAs: []string{"a", "b"}},
+		Ignored:  "ignored",
+	}
+	cred, err := Marshal(&in)
+	assert.Nil(t, err)
+	assert.Equal(t, "db", cred.TargetName)
+	assert.Equal(t, TypeGeneric, cred.Type)
+	assert.Equal(t, encodeUTF16LE("pässword"), cred.CredentialBlob)
+	host, _ := cred.GetAttribute("host")
+	assert.Equal(t, []byte("db.example.com"), host)
+	address, _ := cred.GetAttribute("address")
+	assert.Equal(t, []byte("10.0.0.1"), address)
+	cas, _ := cred.GetAttribute("cas")
+	assert.Equal(t, []byte(`["a","b"]`), cas)
+	assert.Len(t, cred.Attributes, 9)
+
+	var out fixtureConnection
+	assert.Nil(t, Unmarshal(cred, &out))
+	in.Ignored = ""
+	assert.True(t, in.Rotated.Equal(out.Rotated))
+	out.Rotated = in.Rotated
+	assert.Equal(t, in, out)
+}
+
+func TestMarshal_ZeroValue(t *testing.T) {
+	in := fixtureConnection{Target: "db"}
+	cred, err := Marshal(&in)
+	assert.Nil(t, err)
+	rotated, _ := cred.GetAttribute("rotated")
+	assert.Equal(t, make([]byte, 8), rotated)
+
+	var out fixtureConnection
+	assert.Nil(t, Unmarshal(cred, &out))
+	assert.True(t, out.Rotated.IsZero())
+	assert.Equal(t, in, out)
+}
+
+func TestMarshal_Limits(t *testing.T) {
+	_, err := Marshal(struct {
+		Value string `wincred:"attr=value"`
+	}{strings.Repeat("x", 257)})
+	assert.True(t, errors.Is(err, ErrAttributeTooLarge))
+
+	_, err = Marshal(struct {
+		Value []byte `wincred:"blob"`
+	}{make([]byte, 2561)})
+	assert.True(t, errors.Is(err, ErrBlobTooLarge))
+}
+
+func TestMarshal_InvalidTags(t *testing.T) {
+	_, err := Marshal(struct {
+		Value int `wincred:"username"`
+	}{})
+	assert.True(t, errors.Is(err, ErrInvalidStructTag))
+
+	_, err = Marshal(struct {
+		Value string `wincred:"attr=x,gzip"`
+	}{})
+	assert.True(t, errors.Is(err, ErrInvalidStructTag))
+
+	_, err = Marshal("not a struct")
+	assert.True(t, errors.Is(err, ErrInvalidStructTag))
+
+	assert.True(t, errors.Is(Unmarshal(new(Credential), fixtureConnection{}), ErrInvalidStructTag))
+}
+
+func TestUnmarshal_InvalidEncoding(t *testing.T) {
+	cred := new(Credential)
+	cred.setAttribute("port", []byte{1, 2})
+	var out fixtureConnection
+	err := Unmarshal(cred, &out)
+	assert.True(t, errors.Is(err, ErrInvalidEncoding))
+	assert.Contains(t, err.Error(), `attribute "port"`)
+}
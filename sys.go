@@ -81,6 +81,7 @@ func sysCredRead(targetName string, typ sysCRED_TYPE) (*Credential, error) {
 		return nil, err
 	}
 	defer procCredFree.Call(uintptr(unsafe.Pointer(pcred)))
+	defer sysWipeCredential(pcred)
 
 	return sysToCredential(pcred), nil
 }
@@ -142,6 +143,7 @@ func sysCredEnumerate(filter string, all bool) ([]*Credential, error) {
 	creds := make([]*Credential, count, count)
 	for i, cred := range credsSlice {
 		creds[i] = sysToCredential(cred)
+		sysWipeCredential(cred)
 	}
 
 	return creds, nil
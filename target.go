@@ -0,0 +1,212 @@
+package wincred
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in target name schemes.
+const (
+	SchemeLegacyGeneric    = "LegacyGeneric"
+	SchemeDomain           = "Domain"
+	SchemeMicrosoftAccount = "MicrosoftAccount"
+	SchemeTermSrv          = "TERMSRV"
+	SchemeGit              = "git"
+	SchemeURL              = "url"
+)
+
+// TargetName is the structured form of a credential target name, as returned
+// by ParseTargetName. Which fields are set depends on the scheme.
+type TargetName struct {
+	// Scheme is the name of the registered scheme the target name matched.
+	// It is empty for target names that did not match any scheme.
+	Scheme string
+	// Protocol is the URL scheme of URL based target names, e.g. "https".
+	Protocol string
+	User     string
+	Host     string
+	// Port is zero if the target name does not contain a port.
+	Port int
+	Path string
+	// Target holds the opaque part of target names without further structure,
+	// e.g. the complete target name if it did not match any scheme.
+	Target string
+}
+
+// TargetScheme describes a convention for target names.
+type TargetScheme struct {
+	// Name identifies the scheme and is stored in TargetName.Scheme.
+	Name string
+	// Parse returns the structured form of the given target name and whether
+	// the target name follows the scheme.
+	Parse func(target string) (TargetName, bool)
+	// Format returns the target name for the given structured form.
+	Format func(name TargetName) string
+}
+
+var targetSchemes = struct {
+	sync.RWMutex
+	schemes []TargetScheme
+}{schemes: []TargetScheme{
+	prefixScheme(SchemeLegacyGeneric, "LegacyGeneric:target=", func(name *TargetName) *string { return &name.Target }),
+	prefixScheme(SchemeDomain, "Domain:target=", func(name *TargetName) *string { return &name.Host }),
+	prefixScheme(SchemeMicrosoftAccount, "MicrosoftAccount:user=", func(name *TargetName) *string { return &name.User }),
+	{Name: SchemeTermSrv, Parse: parseTermSrvTarget, Format: formatTermSrvTarget},
+	{Name: SchemeGit, Parse: parseGitTarget, Format: formatGitTarget},
+	{Name: SchemeURL, Parse: parseURLTarget, Format: formatURLTarget},
+}}
+
+// RegisterTargetScheme adds a target name scheme to the ones known by
+// ParseTargetName. A scheme with the same name as an already registered one
+// replaces it. Schemes are tried in the order of their registration, after
+// the built-in ones.
+func RegisterTargetScheme(scheme TargetScheme) {
+	targetSchemes.Lock()
+	defer targetSchemes.Unlock()
+	for i, existing := range targetSchemes.schemes {
+		if existing.Name == scheme.Name {
+			targetSchemes.schemes[i] = scheme
+			return
+		}
+	}
+	targetSchemes.schemes = append(targetSchemes.schemes, scheme)
+}
+
+// ParseTargetName parses the given target name using the registered schemes.
+// Target names that do not match any scheme are returned with an empty
+// scheme and the complete target name in the Target field.
+func ParseTargetName(target string) TargetName {
+	targetSchemes.RLock()
+	defer targetSchemes.RUnlock()
+	for _, scheme := range targetSchemes.schemes {
+		if name, ok := scheme.Parse(target); ok {
+			name.Scheme = scheme.Name
+			return name
+		}
+	}
+	return TargetName{Target: target}
+}
+
+// String formats the target name according to its scheme.
+// Target names with an unknown scheme are formatted as their Target field.
+func (t TargetName) String() string {
+	targetSchemes.RLock()
+	defer targetSchemes.RUnlock()
+	for _, scheme := range targetSchemes.schemes {
+		if scheme.Name == t.Scheme {
+			return scheme.Format(t)
+		}
+	}
+	return t.Target
+}
+
+// Service returns a key that identifies the service the target name refers
+// to, suitable for grouping credentials: the lower-case host and port if the
+// target name has a host, otherwise its opaque target.
+func (t TargetName) Service() string {
+	if t.Host == "" {
+		return t.Target
+	}
+	return strings.ToLower(joinHostPort(t.Host, t.Port))
+}
+
+// prefixScheme returns a scheme of the form "<prefix><value>", where the
+// value is stored in the field returned by field.
+func prefixScheme(name, prefix string, field func(*TargetName) *string) TargetScheme {
+	return TargetScheme{
+		Name: name,
+		Parse: func(target string) (TargetName, bool) {
+			var result TargetName
+			if !hasPrefixFold(target, prefix) {
+				return result, false
+			}
+			*field(&result) = target[len(prefix):]
+			return result, true
+		},
+		Format: func(name TargetName) string {
+			return prefix + *field(&name)
+		},
+	}
+}
+
+func parseTermSrvTarget(target string) (TargetName, bool) {
+	const prefix = "TERMSRV/"
+	if !hasPrefixFold(target, prefix) || len(target) == len(prefix) {
+		return TargetName{}, false
+	}
+	host, port := splitHostPort(target[len(prefix):])
+	return TargetName{Host: host, Port: port}, true
+}
+
+func formatTermSrvTarget(name TargetName) string {
+	return "TERMSRV/" + joinHostPort(name.Host, name.Port)
+}
+
+func parseGitTarget(target string) (TargetName, bool) {
+	if !strings.HasPrefix(target, gitTargetPrefix) {
+		return TargetName{}, false
+	}
+	return parseURLTarget(target[len(gitTargetPrefix):])
+}
+
+func formatGitTarget(name TargetName) string {
+	return gitTargetPrefix + formatURLTarget(name)
+}
+
+func parseURLTarget(target string) (TargetName, bool) {
+	if !strings.Contains(target, "://") {
+		return TargetName{}, false
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return TargetName{}, false
+	}
+	result := TargetName{Protocol: u.Scheme, Host: u.Hostname(), Path: u.Path}
+	if u.User != nil {
+		result.User = u.User.Username()
+	}
+	if port := u.Port(); port != "" {
+		if result.Port, err = strconv.Atoi(port); err != nil {
+			return TargetName{}, false
+		}
+	}
+	return result, true
+}
+
+func formatURLTarget(name TargetName) string {
+	u := url.URL{Scheme: name.Protocol, Host: joinHostPort(name.Host, name.Port), Path: name.Path}
+	if name.User != "" {
+		u.User = url.User(name.User)
+	}
+	return u.String()
+}
+
+// splitHostPort splits an optional numeric port off the given host.
+func splitHostPort(hostport string) (string, int) {
+	i := strings.LastIndex(hostport, ":")
+	if i < 0 || strings.Count(hostport, ":") > 1 && !strings.HasPrefix(hostport, "[") {
+		return hostport, 0
+	}
+	port, err := strconv.Atoi(hostport[i+1:])
+	if err != nil || port <= 0 || port > 65535 {
+		return hostport, 0
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(hostport[:i], "["), "]"), port
+}
+
+// joinHostPort appends a non-zero port to the given host.
+func joinHostPort(host string, port int) string {
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port == 0 {
+		return host
+	}
+	return host + ":" + strconv.Itoa(port)
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,46 @@
+package wincred
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTargetName(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected TargetName
+	}{
+		{"LegacyGeneric:target=myGoApplication", TargetName{Scheme: SchemeLegacyGeneric, Target: "myGoApplication"}},
+		{"Domain:target=*.contoso.com", TargetName{Scheme: SchemeDomain, Host: "*.contoso.com"}},
+		{"MicrosoftAccount:user=alice@example.com", TargetName{Scheme: SchemeMicrosoftAccount, User: "alice@example.com"}},
+		{"TERMSRV/host.contoso.com", TargetName{Scheme: SchemeTermSrv, Host: "host.contoso.com"}},
+		{"TERMSRV/host:3390", TargetName{Scheme: SchemeTermSrv, Host: "host", Port: 3390}},
+		{"git:https://bob@github.com:8443/org/repo", TargetName{Scheme: SchemeGit, Protocol: "https", User: "bob", Host: "github.com", Port: 8443, Path: "/org/repo"}},
+		{"https://example.com/api", TargetName{Scheme: SchemeURL, Protocol: "https", Host: "example.com", Path: "/api"}},
+		{"myGoApplication", TargetName{Target: "myGoApplication"}},
+	}
+	for _, test := range tests {
+		name := ParseTargetName(test.target)
+		assert.Equal(t, test.expected, name, test.target)
+		assert.Equal(t, test.target, name.String(), test.target)
+	}
+}
+
+func TestTargetName_Service(t *testing.T) {
+	assert.Equal(t, "github.com", ParseTargetName("git:https://github.com").Service())
+	assert.Equal(t, "host:3390", ParseTargetName("TERMSRV/HOST:3390").Service())
+	assert.Equal(t, "myGoApplication", ParseTargetName("myGoApplication").Service())
+}
+
+func TestRegisterTargetScheme(t *testing.T) {
+	RegisterTargetScheme(prefixScheme("TestScheme", "test:", func(name *TargetName) *string { return &name.Host }))
+	defer func() {
+		targetSchemes.Lock()
+		targetSchemes.schemes = targetSchemes.schemes[:len(targetSchemes.schemes)-1]
+		targetSchemes.Unlock()
+	}()
+	name := ParseTargetName("test:example.com")
+	assert.Equal(t, TargetName{Scheme: "TestScheme", Host: "example.com"}, name)
+	assert.Equal(t, "test:example.com", TargetName{Scheme: "TestScheme", Host: "example.com"}.String())
+}
@@ -0,0 +1,268 @@
+package wincred
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Attributes recording how typed values are stored in the credential blob.
+const (
+	AttributeCodec         = "wincred:codec"
+	AttributeSchemaVersion = "wincred:schema"
+)
+
+var (
+	// ErrCodecMismatch is returned when reading a typed value with a codec
+	// other than the one it was stored with.
+	ErrCodecMismatch = errors.New("codec mismatch")
+
+	// ErrSchemaVersion is returned when reading a typed value stored with a
+	// schema version other than the one of the requested type.
+	ErrSchemaVersion = errors.New("schema version mismatch")
+)
+
+// Codec converts values to and from credential blobs.
+type Codec interface {
+	// Name identifies the codec. It is stored with the credential.
+	Name() string
+	// Encode returns the encoding of the value v points to.
+	Encode(v interface{}) ([]byte, error)
+	// Decode decodes the given data into the value v points to.
+	Decode(data []byte, v interface{}) error
+}
+
+// Versioned is implemented by types whose schema version is recorded when
+// storing them with PutTyped.
+type Versioned interface {
+	SchemaVersion() int
+}
+
+// Built-in codecs.
+var (
+	// CodecJSON encodes values as JSON.
+	CodecJSON Codec = jsonCodec{}
+	// CodecGob encodes values with encoding/gob.
+	CodecGob Codec = gobCodec{}
+	// CodecProto encodes protocol buffer messages that provide Marshal and
+	// Unmarshal methods, like those generated by gogo/protobuf, or that
+	// implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+	CodecProto Codec = protoCodec{}
+	// CodecUTF16 encodes strings as UTF-16 little-endian, like the passwords
+	// stored by Windows applications.
+	CodecUTF16 Codec = utf16Codec{}
+	// CodecRaw stores byte slices as is.
+	CodecRaw Codec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                            { return "json" }
+func (jsonCodec) Encode(v interface{}) ([]byte, error)    { return json.Marshal(v) }
+func (jsonCodec) Decode(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string { return "proto" }
+
+// protoMessage returns the message v points to if v is a pointer to a message
+// pointer, allocating a new message if necessary.
+func protoMessage(v interface{}) interface{} {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Ptr {
+		return v
+	}
+	if value.Elem().IsNil() {
+		value.Elem().Set(reflect.New(value.Elem().Type().Elem()))
+	}
+	return value.Elem().Interface()
+}
+
+func (protoCodec) Encode(v interface{}) ([]byte, error) {
+	switch m := protoMessage(v).(type) {
+	case interface{ Marshal() ([]byte, error) }:
+		return m.Marshal()
+	case encoding.BinaryMarshaler:
+		return m.MarshalBinary()
+	}
+	return nil, fmt.Errorf("%w: %T is not a protocol buffer message", ErrUnsupportedType, v)
+}
+
+func (protoCodec) Decode(data []byte, v interface{}) error {
+	switch m := protoMessage(v).(type) {
+	case interface{ Unmarshal([]byte) error }:
+		return m.Unmarshal(data)
+	case encoding.BinaryUnmarshaler:
+		return m.UnmarshalBinary(data)
+	}
+	return fmt.Errorf("%w: %T is not a protocol buffer message", ErrUnsupportedType, v)
+}
+
+type utf16Codec struct{}
+
+func (utf16Codec) Name() string { return "utf16" }
+
+func (utf16Codec) Encode(v interface{}) ([]byte, error) {
+	switch s := v.(type) {
+	case string:
+		return encodeUTF16LE(s), nil
+	case *string:
+		return encodeUTF16LE(*s), nil
+	}
+	return nil, fmt.Errorf("%w: %T is not a string", ErrUnsupportedType, v)
+}
+
+func (utf16Codec) Decode(data []byte, v interface{}) error {
+	s, ok := v.(*string)
+	if !ok {
+		return fmt.Errorf("%w: %T is not a string pointer", ErrUnsupportedType, v)
+	}
+	decoded, ok := decodeUTF16LE(data)
+	if !ok {
+		return fmt.Errorf("%w: not UTF-16", ErrInvalidEncoding)
+	}
+	*s = decoded
+	return nil
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "raw" }
+
+func (rawCodec) Encode(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return append([]byte{}, b...), nil
+	case *[]byte:
+		return append([]byte{}, *b...), nil
+	}
+	return nil, fmt.Errorf("%w: %T is not a byte slice", ErrUnsupportedType, v)
+}
+
+func (rawCodec) Decode(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("%w: %T is not a byte slice pointer", ErrUnsupportedType, v)
+	}
+	*b = append([]byte{}, data...)
+	return nil
+}
+
+// schemaVersion returns the schema version of the value v points to, if its
+// type implements Versioned.
+func schemaVersion[T any](v *T) (int, bool) {
+	if versioned, ok := interface{}(*v).(Versioned); ok {
+		return versioned.SchemaVersion(), true
+	}
+	if versioned, ok := interface{}(v).(Versioned); ok {
+		return versioned.SchemaVersion(), true
+	}
+	return 0, false
+}
+
+// encodeTyped returns a generic credential holding the encoded value.
+func encodeTyped[T any](targetName string, value T, codec Codec) (*GenericCredential, error) {
+	data, err := codec.Encode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxCredentialBlobSize {
+		wipeBytes(data)
+		return nil, fmt.Errorf("%w: %d bytes", ErrBlobTooLarge, len(data))
+	}
+	cred := NewGenericCredential(targetName)
+	cred.CredentialBlob = data
+	cred.setAttribute(AttributeCodec, []byte(codec.Name()))
+	if version, ok := schemaVersion(&value); ok {
+		cred.setAttribute(AttributeSchemaVersion, []byte(strconv.Itoa(version)))
+	}
+	return cred, nil
+}
+
+// storedSchemaVersion returns the schema version recorded in the credential,
+// or zero if there is none.
+func storedSchemaVersion(cred *Credential) (int, error) {
+	value, ok := cred.GetAttribute(AttributeSchemaVersion)
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("%w: schema version %q", ErrInvalidEncoding, value)
+	}
+	return version, nil
+}
+
+// decodeTyped decodes the value stored in the given credential, after
+// upgrading it in memory with the registered migrations.
+func decodeTyped[T any](cred *Credential, codec Codec) (T, error) {
+	var result T
+	if stored, _ := cred.GetAttribute(AttributeCodec); string(stored) != codec.Name() {
+		return result, fmt.Errorf("%w: %q was stored with codec %q, not %q", ErrCodecMismatch, cred.TargetName, stored, codec.Name())
+	}
+	if _, _, err := Migrate(cred); err != nil {
+		return result, err
+	}
+	if version, ok := schemaVersion(&result); ok {
+		stored, err := storedSchemaVersion(cred)
+		if err != nil {
+			return result, err
+		}
+		if stored != version {
+			return result, fmt.Errorf("%w: %q has version %d, expected %d", ErrSchemaVersion, cred.TargetName, stored, version)
+		}
+	}
+	if err := codec.Decode(cred.CredentialBlob, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// PutTyped stores the value in the generic credential with the given target
+// name, encoded with the codec. The name of the codec and, if T implements
+// Versioned, the schema version are recorded in the credential attributes.
+func PutTyped[T any](targetName string, value T, codec Codec) error {
+	cred, err := encodeTyped(targetName, value, codec)
+	if err != nil {
+		return err
+	}
+	defer wipeBytes(cred.CredentialBlob)
+	return cred.Write()
+}
+
+// GetTyped reads the value stored with PutTyped in the generic credential
+// with the given target name. Values stored with an older schema version are
+// upgraded in memory with the migrations registered by RegisterMigration.
+// It fails with ErrCodecMismatch if the value was stored with another codec
+// and with ErrSchemaVersion if T implements Versioned and the schema version
+// still differs.
+func GetTyped[T any](targetName string, codec Codec) (T, error) {
+	cred, err := GetGenericCredential(targetName)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer cred.Wipe()
+	return decodeTyped[T](&cred.Credential, codec)
+}
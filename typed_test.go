@@ -0,0 +1,97 @@
+package wincred
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixtureConfig struct {
+	Endpoint string
+	Retries  int
+}
+
+type fixtureVersionedConfig struct {
+	Endpoint string
+}
+
+func (fixtureVersionedConfig) SchemaVersion() int { return 2 }
+
+// fixtureMessage mimics a generated protocol buffer message.
+type fixtureMessage struct {
+	ID uint64
+}
+
+func (m *fixtureMessage) Marshal() ([]byte, error) {
+	return appendUvarint(nil, m.ID), nil
+}
+
+func (m *fixtureMessage) Unmarshal(data []byte) error {
+	id, n := binary.Uvarint(data)
+	if n <= 0 {
+		return errors.New("bad message")
+	}
+	m.ID = id
+	return nil
+}
+
+func TestTyped_RoundTrip(t *testing.T) {
+	value := fixtureConfig{Endpoint: "https://example.com", Retries: 3}
+	for _, codec := range []Codec{CodecJSON, CodecGob} {
+		cred, err := encodeTyped("target", value, codec)
+		assert.Nil(t, err, codec.Name())
+		codecName, _ := cred.GetAttribute(AttributeCodec)
+		assert.Equal(t, codec.Name(), string(codecName))
+		_, ok := cred.GetAttribute(AttributeSchemaVersion)
+		assert.False(t, ok)
+		result, err := decodeTyped[fixtureConfig](&cred.Credential, codec)
+		assert.Nil(t, err, codec.Name())
+		assert.Equal(t, value, result)
+	}
+}
+
+func TestTyped_Codecs(t *testing.T) {
+	cred, err := encodeTyped("target", "pässword", CodecUTF16)
+	assert.Nil(t, err)
+	assert.Equal(t, encodeUTF16LE("pässword"), cred.CredentialBlob)
+	s, err := decodeTyped[string](&cred.Credential, CodecUTF16)
+	assert.Nil(t, err)
+	assert.Equal(t, "pässword", s)
+
+	cred, err = encodeTyped("target", []byte{1, 2, 3}, CodecRaw)
+	assert.Nil(t, err)
+	b, err := decodeTyped[[]byte](&cred.Credential, CodecRaw)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, b)
+
+	cred, err = encodeTyped("target", &fixtureMessage{ID: 300}, CodecProto)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xac, 0x02}, cred.CredentialBlob)
+	m, err := decodeTyped[*fixtureMessage](&cred.Credential, CodecProto)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(300), m.ID)
+
+	_, err = encodeTyped("target", 42, CodecUTF16)
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+}
+
+func TestTyped_Mismatch(t *testing.T) {
+	cred, err := encodeTyped("target", fixtureVersionedConfig{Endpoint: "x"}, CodecJSON)
+	assert.Nil(t, err)
+	version, _ := cred.GetAttribute(AttributeSchemaVersion)
+	assert.Equal(t, "2", string(version))
+
+	_, err = decodeTyped[fixtureVersionedConfig](&cred.Credential, CodecGob)
+	assert.True(t, errors.Is(err, ErrCodecMismatch))
+
+	cred.setAttribute(AttributeSchemaVersion, []byte("1"))
+	_, err = decodeTyped[fixtureVersionedConfig](&cred.Credential, CodecJSON)
+	assert.True(t, errors.Is(err, ErrSchemaVersion))
+}
+
+func TestTyped_BlobTooLarge(t *testing.T) {
+	_, err := encodeTyped("target", make([]byte, MaxCredentialBlobSize+1), CodecRaw)
+	assert.True(t, errors.Is(err, ErrBlobTooLarge))
+}
@@ -27,6 +27,31 @@ const (
 	PersistEnterprise CredentialPersistence = 0x3
 )
 
+// CredentialType describes the kind of a credential.
+// More information about the available kinds of credentials can be found on
+// Docs: https://docs.microsoft.com/en-us/windows/desktop/SecAuthN/kinds-of-credentials
+type CredentialType uint32
+
+const (
+	// TypeGeneric is the type of generic credentials (see GenericCredential).
+	TypeGeneric CredentialType = 0x1
+
+	// TypeDomainPassword is the type of domain-password credentials (see DomainPassword).
+	TypeDomainPassword CredentialType = 0x2
+
+	// TypeDomainCertificate is the type of domain-certificate credentials.
+	TypeDomainCertificate CredentialType = 0x3
+
+	// TypeDomainVisiblePassword is the type of domain-visible-password credentials.
+	TypeDomainVisiblePassword CredentialType = 0x4
+
+	// TypeGenericCertificate is the type of generic-certificate credentials.
+	TypeGenericCertificate CredentialType = 0x5
+
+	// TypeDomainExtended is the type of extended domain credentials.
+	TypeDomainExtended CredentialType = 0x6
+)
+
 // CredentialAttribute represents an application-specific attribute of a credential.
 type CredentialAttribute struct {
 	Keyword string
@@ -45,6 +70,7 @@ type Credential struct {
 	TargetAlias    string
 	UserName       string
 	Persist        CredentialPersistence
+	Type           CredentialType
 }
 
 // GenericCredential holds a credential for generic usage.
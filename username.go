@@ -0,0 +1,164 @@
+package wincred
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// UserNameFormat identifies the form of a credential user name.
+type UserNameFormat int
+
+const (
+	// UserNamePlain is a user name without domain, e.g. "alice".
+	UserNamePlain UserNameFormat = iota + 1
+
+	// UserNameDownLevel is a down-level logon name, e.g. "CONTOSO\alice".
+	UserNameDownLevel
+
+	// UserNameUPN is a user principal name, e.g. "alice@contoso.com".
+	UserNameUPN
+
+	// UserNameLocal is a local account name, e.g. ".\alice".
+	UserNameLocal
+
+	// UserNameMarshaled is a credential reference as returned by
+	// CredMarshalCredential, e.g. "@@BAAAAAAAA...".
+	UserNameMarshaled
+)
+
+var userNameFormatNames = map[UserNameFormat]string{
+	UserNamePlain:     "plain",
+	UserNameDownLevel: "down-level",
+	UserNameUPN:       "upn",
+	UserNameLocal:     "local",
+	UserNameMarshaled: "marshaled",
+}
+
+// String returns the name of the user name format.
+func (t UserNameFormat) String() string {
+	if name, ok := userNameFormatNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("UserNameFormat(%d)", int(t))
+}
+
+// MarshaledCredentialType is the type of a marshaled credential reference,
+// as defined by the CRED_MARSHAL_TYPE enumeration.
+type MarshaledCredentialType int
+
+const (
+	// MarshaledCertificate references a certificate credential.
+	MarshaledCertificate MarshaledCredentialType = iota + 1
+
+	// MarshaledUsernameTarget references the credential of a user name target.
+	MarshaledUsernameTarget
+
+	// MarshaledBinaryBlob references a binary blob credential.
+	MarshaledBinaryBlob
+
+	// MarshaledUsernameForPacked references the user name of packed
+	// credentials.
+	MarshaledUsernameForPacked
+
+	// MarshaledBinaryBlobForSystem references a binary blob credential of the
+	// system.
+	MarshaledBinaryBlobForSystem
+)
+
+// maxUserNameLength is CRED_MAX_USERNAME_LENGTH, in UTF-16 code units.
+const maxUserNameLength = 513
+
+// Characters not allowed in user and domain names. The user part of UPNs,
+// like "alice+tag@outlook.com", allows more characters than down-level names.
+const (
+	invalidUserChars    = "\"/\\[]:;|=,+*?<>"
+	invalidUPNUserChars = "@\\"
+	invalidDomainChars  = "\"/\\[]:;|=,+*?<>@"
+	marshaledPrefix     = "@@"
+	marshaledAlphabet   = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789#-"
+)
+
+// UserName is the parsed form of a credential user name.
+type UserName struct {
+	Format UserNameFormat
+	// Domain is the domain of down-level and UPN user names and "." for
+	// local user names.
+	Domain string
+	// User is the user name without domain. It is the encoded reference for
+	// marshaled user names.
+	User string
+	// MarshaledType is the type of marshaled credential references.
+	MarshaledType MarshaledCredentialType
+}
+
+// ParseUserName parses and validates the given user name. The returned error
+// wraps ErrBadUsername and describes why the user name is invalid.
+func ParseUserName(userName string) (UserName, error) {
+	badUserName := func(format string, args ...interface{}) (UserName, error) {
+		return UserName{}, fmt.Errorf("%w: %q: %s", ErrBadUsername, userName, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case userName == "":
+		return badUserName("empty user name")
+	case len(utf16.Encode([]rune(userName))) > maxUserNameLength:
+		return badUserName("longer than %d characters", maxUserNameLength)
+	case strings.HasPrefix(userName, marshaledPrefix):
+		encoded := userName[len(marshaledPrefix):]
+		// CredMarshalCredential encodes the type as 'A' + CRED_MARSHAL_TYPE
+		if len(encoded) < 2 || encoded[0] < 'A'+byte(MarshaledCertificate) || encoded[0] > 'A'+byte(MarshaledBinaryBlobForSystem) {
+			return badUserName("unknown marshaled credential type")
+		}
+		if i := strings.IndexFunc(encoded[1:], func(r rune) bool { return !strings.ContainsRune(marshaledAlphabet, r) }); i >= 0 {
+			return badUserName("invalid character in marshaled credential")
+		}
+		return UserName{Format: UserNameMarshaled, User: encoded[1:], MarshaledType: MarshaledCredentialType(encoded[0] - 'A')}, nil
+	}
+
+	result := UserName{Format: UserNamePlain, User: userName}
+	if i := strings.Index(userName, `\`); i >= 0 {
+		result.Domain, result.User = userName[:i], userName[i+1:]
+		result.Format = UserNameDownLevel
+		if result.Domain == "." {
+			result.Format = UserNameLocal
+		} else if result.Domain == "" {
+			return badUserName("empty domain")
+		} else if strings.ContainsAny(result.Domain, invalidDomainChars) {
+			return badUserName("invalid character in domain")
+		}
+	} else if i := strings.LastIndex(userName, "@"); i >= 0 {
+		result.User, result.Domain = userName[:i], userName[i+1:]
+		result.Format = UserNameUPN
+		if result.Domain == "" {
+			return badUserName("empty domain")
+		}
+		if strings.ContainsAny(result.Domain, invalidDomainChars) {
+			return badUserName("invalid character in domain")
+		}
+	}
+	if result.User == "" {
+		return badUserName("empty user")
+	}
+	invalid := strings.ContainsAny(result.User, invalidUserChars)
+	if result.Format == UserNameUPN {
+		invalid = strings.ContainsAny(result.User, invalidUPNUserChars) || strings.IndexFunc(result.User, unicode.IsControl) >= 0
+	}
+	if invalid {
+		return badUserName("invalid character in user")
+	}
+	return result, nil
+}
+
+// String formats the user name in its original form.
+func (t UserName) String() string {
+	switch t.Format {
+	case UserNameDownLevel, UserNameLocal:
+		return FormatDownLevelLogonName(t.Domain, t.User)
+	case UserNameUPN:
+		return FormatUPN(t.User, t.Domain)
+	case UserNameMarshaled:
+		return marshaledPrefix + string(rune('A'+t.MarshaledType)) + t.User
+	}
+	return t.User
+}
@@ -0,0 +1,73 @@
+package wincred
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserName(t *testing.T) {
+	tests := []struct {
+		userName string
+		expected UserName
+	}{
+		{"alice", UserName{Format: UserNamePlain, User: "alice"}},
+		{`CONTOSO\alice`, UserName{Format: UserNameDownLevel, Domain: "CONTOSO", User: "alice"}},
+		{"alice@contoso.com", UserName{Format: UserNameUPN, Domain: "contoso.com", User: "alice"}},
+		{"alice+tag@outlook.com", UserName{Format: UserNameUPN, Domain: "outlook.com", User: "alice+tag"}},
+		{"a=b,c*d@contoso.com", UserName{Format: UserNameUPN, Domain: "contoso.com", User: "a=b,c*d"}},
+		{`.\alice`, UserName{Format: UserNameLocal, Domain: ".", User: "alice"}},
+		{"@@BAAAAAAAAAA#-z", UserName{Format: UserNameMarshaled, User: "AAAAAAAAAA#-z", MarshaledType: MarshaledCertificate}},
+		{"@@CAAAAAAAA", UserName{Format: UserNameMarshaled, User: "AAAAAAAA", MarshaledType: MarshaledUsernameTarget}},
+		{"@@DAAAAAAAA", UserName{Format: UserNameMarshaled, User: "AAAAAAAA", MarshaledType: MarshaledBinaryBlob}},
+		{"@@FAAAAAAAA", UserName{Format: UserNameMarshaled, User: "AAAAAAAA", MarshaledType: MarshaledBinaryBlobForSystem}},
+	}
+	for _, test := range tests {
+		name, err := ParseUserName(test.userName)
+		assert.Nil(t, err, test.userName)
+		assert.Equal(t, test.expected, name, test.userName)
+		assert.Equal(t, test.userName, name.String())
+	}
+}
+
+func TestParseUserName_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"":                       "empty user name",
+		`\alice`:                 "empty domain",
+		`CONTOSO\`:               "empty user",
+		`CONTOSO\a\b`:            "invalid character in user",
+		`CON*TOSO\alice`:         "invalid character in domain",
+		"alice@":                 "empty domain",
+		"@contoso.com":           "empty user",
+		"a@b@contoso.com":        "invalid character in user",
+		"ali:ce":                 "invalid character in user",
+		"ali+ce":                 "invalid character in user",
+		"ali\tce@contoso.com":    "invalid character in user",
+		"@@Z123":                 "unknown marshaled credential type",
+		"@@A123":                 "unknown marshaled credential type",
+		"@@G123":                 "unknown marshaled credential type",
+		"@@B12$":                 "invalid character in marshaled credential",
+		strings.Repeat("a", 514): "longer than 513 characters",
+	}
+	for userName, message := range tests {
+		_, err := ParseUserName(userName)
+		assert.True(t, errors.Is(err, ErrBadUsername), userName)
+		assert.Contains(t, err.Error(), message, userName)
+	}
+}
+
+func TestDomainPassword_WriteValidatesUserName(t *testing.T) {
+	cred := NewDomainPassword("TERMSRV/host")
+	cred.UserName = `CONTOSO\`
+	err := cred.Write()
+	assert.True(t, errors.Is(err, ErrBadUsername))
+	assert.Contains(t, err.Error(), "empty user")
+}
+
+func TestDomainPassword_WriteEmptyTarget(t *testing.T) {
+	cred := NewDomainPassword("")
+	cred.UserName = `CONTOSO\`
+	assert.Equal(t, ErrInvalidParameter, cred.Write())
+}
@@ -5,7 +5,10 @@
 // Docs: https://docs.microsoft.com/en-us/windows/desktop/SecAuthN/credentials-management
 package wincred
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 const (
 	// ErrElementNotFound is the error that is returned if a requested element cannot be found.
@@ -21,6 +24,22 @@ const (
 	ErrBadUsername = sysERROR_BAD_USERNAME
 )
 
+// ErrTypeMismatch is returned when writing a credential whose Type field
+// disagrees with the kind of credential it is written as.
+var ErrTypeMismatch = errors.New("credential type mismatch")
+
+// checkType sets the type of the credential if it is unset, and fails with
+// ErrTypeMismatch if it is set to a different type.
+func (t *Credential) checkType(typ CredentialType) error {
+	if t.Type == 0 {
+		t.Type = typ
+	}
+	if t.Type != typ {
+		return fmt.Errorf("%w: %v credential written as %v", ErrTypeMismatch, t.Type, typ)
+	}
+	return nil
+}
+
 // GetGenericCredential fetches the generic credential with the given name from Windows credential manager.
 // It returns nil and an error if the credential could not be found or an error occurred.
 func GetGenericCredential(targetName string) (*GenericCredential, error) {
@@ -38,11 +57,16 @@ func NewGenericCredential(targetName string) (result *GenericCredential) {
 	result = new(GenericCredential)
 	result.TargetName = targetName
 	result.Persist = PersistLocalMachine
+	result.Type = TypeGeneric
 	return
 }
 
 // Write persists the generic credential object to Windows credential manager.
+// An unset Type is set to TypeGeneric; any other type fails with ErrTypeMismatch.
 func (t *GenericCredential) Write() (err error) {
+	if err = t.checkType(TypeGeneric); err != nil {
+		return
+	}
 	err = sysCredWrite(&t.Credential, sysCRED_TYPE_GENERIC)
 	return
 }
@@ -70,11 +94,25 @@ func NewDomainPassword(targetName string) (result *DomainPassword) {
 	result = new(DomainPassword)
 	result.TargetName = targetName
 	result.Persist = PersistLocalMachine
+	result.Type = TypeDomainPassword
 	return
 }
 
 // Write persists the domain-password credential to Windows credential manager.
+// An empty target name fails with ErrInvalidParameter. The user name is then
+// validated with ParseUserName before the credential is written.
+// An unset Type is set to TypeDomainPassword; any other type fails with
+// ErrTypeMismatch.
 func (t *DomainPassword) Write() (err error) {
+	if t.TargetName == "" {
+		return ErrInvalidParameter
+	}
+	if err = t.checkType(TypeDomainPassword); err != nil {
+		return
+	}
+	if _, err = ParseUserName(t.UserName); err != nil {
+		return
+	}
 	err = sysCredWrite(&t.Credential, sysCRED_TYPE_DOMAIN_PASSWORD)
 	return
 }
@@ -87,7 +125,9 @@ func (t *DomainPassword) Delete() (err error) {
 
 // SetPassword sets the CredentialBlob field of a domain password credential to the given string.
 func (t *DomainPassword) SetPassword(pw string) {
-	t.CredentialBlob = utf16ToByte(utf16FromString(pw))
+	buf := utf16FromString(pw)
+	defer wipeUint16(buf)
+	t.CredentialBlob = utf16ToByte(buf)
 }
 
 // List retrieves all credentials of the Credentials store.
@@ -4,7 +4,11 @@
 package wincred
 
 import (
+	"bytes"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -90,6 +94,18 @@ func TestGenericCredential_WriteEmpty(t *testing.T) {
 	assert.True(t, errors.Is(err, ErrInvalidParameter))
 }
 
+func TestGenericCredential_WriteTypeMismatch(t *testing.T) {
+	cred := NewGenericCredential(testTargetName)
+	cred.Type = TypeDomainPassword
+	err := cred.Write()
+	assert.True(t, errors.Is(err, ErrTypeMismatch))
+
+	cred = &GenericCredential{Credential: Credential{TargetName: testTargetName}}
+	assert.Nil(t, cred.Write())
+	assert.Equal(t, TypeGeneric, cred.Type)
+	assert.Nil(t, cred.Delete())
+}
+
 func TestGenericCredential_DeleteNotFound(t *testing.T) {
 	cred := NewGenericCredential(testTargetNameMissing)
 	err := cred.Delete()
@@ -180,3 +196,332 @@ func TestFilteredList_Empty(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Empty(t, list)
 }
+
+func TestEncryptor_EndToEnd(t *testing.T) {
+	keys := fixtureKeys()
+	enc := NewEncryptor(keys)
+
+	// 1. Write a sealed credential
+	cred := NewGenericCredential(testTargetName)
+	cred.CredentialBlob = []byte("my secret")
+	cred.Persist = PersistSession
+	err := enc.Write(cred)
+	assert.Nil(t, err)
+	assert.Equal(t, "my secret", string(cred.CredentialBlob))
+
+	// 2. The stored blob is encrypted
+	stored, err := GetGenericCredential(testTargetName)
+	assert.Nil(t, err)
+	assert.True(t, IsSealed(&stored.Credential))
+	assert.NotEqual(t, "my secret", string(stored.CredentialBlob))
+
+	// 3. Reading through the encryptor decrypts it
+	cred, err = enc.GetGenericCredential(testTargetName)
+	assert.Nil(t, err)
+	assert.Equal(t, "my secret", string(cred.CredentialBlob))
+
+	// 4. Rotate to a new key
+	keys.Current = "k2"
+	count, err := enc.Rotate(testListFilter)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	stored, err = GetGenericCredential(testTargetName)
+	assert.Nil(t, err)
+	assert.Equal(t, "k2", string(stored.attribute(AttributeKeyID).Value))
+
+	// 5. Delete it
+	err = cred.Delete()
+	assert.Nil(t, err)
+}
+
+func TestExportImport_EndToEnd(t *testing.T) {
+	opts := BundleOptions{Passphrase: []byte("correct horse"), Iterations: 10}
+
+	// 1. Create a credential and export it
+	cred := NewGenericCredential(testTargetName)
+	cred.CredentialBlob = []byte("my secret")
+	cred.Persist = PersistSession
+	err := cred.Write()
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	err = Export(&buf, testListFilter, opts)
+	assert.Nil(t, err)
+
+	// 2. Importing with the default policy skips the existing credential
+	report, err := Import(bytes.NewReader(buf.Bytes()), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Count(ImportSkipped))
+
+	// 3. Importing with the rename policy creates a copy
+	opts.Conflict = ConflictRename
+	report, err = Import(bytes.NewReader(buf.Bytes()), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Count(ImportRenamed))
+	renamed, err := GetGenericCredential(report.Results[0].RenamedTo)
+	assert.Nil(t, err)
+	assert.Equal(t, "my secret", string(renamed.CredentialBlob))
+	assert.Nil(t, renamed.Delete())
+
+	// 4. Delete it and import it again
+	err = cred.Delete()
+	assert.Nil(t, err)
+	report, err = Import(bytes.NewReader(buf.Bytes()), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Count(ImportCreated))
+	cred, err = GetGenericCredential(testTargetName)
+	assert.Nil(t, err)
+	assert.Equal(t, "my secret", string(cred.CredentialBlob))
+	assert.Nil(t, cred.Delete())
+}
+
+func TestImportNetrc_EndToEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "_netrc")
+	err := os.WriteFile(path, []byte("machine "+testTargetName+" login johndoe password s3cr3t\n"), 0600)
+	assert.Nil(t, err)
+
+	// 1. Dry run lists the credential, but does not create it
+	report, err := ImportNetrc(path, FileImportOptions{ImportOptions: ImportOptions{DryRun: true}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Count(ImportCreated))
+	_, err = GetGenericCredential(testTargetName)
+	assert.True(t, errors.Is(err, ErrElementNotFound))
+
+	// 2. Import and scrub the file
+	report, err = ImportNetrc(path, FileImportOptions{Scrub: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Count(ImportCreated))
+	cred, err := GetGenericCredential(testTargetName)
+	assert.Nil(t, err)
+	assert.Equal(t, "johndoe", cred.UserName)
+	assert.Equal(t, "s3cr3t", string(cred.CredentialBlob))
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "s3cr3t")
+
+	// 3. Delete it
+	assert.Nil(t, cred.Delete())
+}
+
+func TestLookup_EndToEnd(t *testing.T) {
+	host := "wincred-lookup.example"
+	cred := NewGenericCredential(host)
+	cred.CredentialBlob = []byte("host")
+	assert.Nil(t, cred.Write())
+	defer cred.Delete()
+	pathCred := NewGenericCredential("git:https://" + host + "/team/repo.git")
+	pathCred.CredentialBlob = []byte("path")
+	assert.Nil(t, pathCred.Write())
+	defer pathCred.Delete()
+
+	found, rule, err := Lookup("https://" + host + "/team/repo.git")
+	assert.Nil(t, err)
+	assert.Equal(t, LookupPath, rule)
+	assert.Equal(t, "path", string(found.CredentialBlob))
+
+	found, rule, err = Lookup("https://" + host + "/other")
+	assert.Nil(t, err)
+	assert.Equal(t, LookupHost, rule)
+	assert.Equal(t, "host", string(found.CredentialBlob))
+}
+
+func TestTyped_EndToEnd(t *testing.T) {
+	type config struct {
+		Endpoint string
+	}
+	assert.Nil(t, PutTyped(testTargetName, config{Endpoint: "https://example.com"}, CodecJSON))
+	defer NewGenericCredential(testTargetName).Delete()
+
+	value, err := GetTyped[config](testTargetName, CodecJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com", value.Endpoint)
+	_, err = GetTyped[config](testTargetName, CodecGob)
+	assert.True(t, errors.Is(err, ErrCodecMismatch))
+}
+
+func TestKV_EndToEnd(t *testing.T) {
+	kv := NewKV(testTargetName)
+	assert.Nil(t, kv.Set("token*1", "grüße"))
+	value, err := kv.Get("token*1")
+	assert.Nil(t, err)
+	assert.Equal(t, "grüße", value)
+
+	keys, err := kv.Keys("token")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"token*1"}, keys)
+
+	deleted, err := kv.Delete("token*1")
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	_, ok, err := kv.Lookup("token*1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	deleted, err = kv.Delete("token*1")
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+}
+
+func TestKV_EndToEndWildcardPrefix(t *testing.T) {
+	kv := NewKV(testTargetName + "*app")
+	other := NewKV(testTargetName + "-other-app")
+	assert.Nil(t, kv.Set("key", "value"))
+	assert.Nil(t, other.Set("key", "other"))
+	defer kv.Delete("key")
+	defer other.Delete("key")
+
+	keys, err := kv.Keys("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+
+	upper := NewKV(strings.ToUpper(testTargetName) + "*APP")
+	keys, err = upper.Keys("k")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+}
+
+func TestGoKeyring_EndToEnd(t *testing.T) {
+	keyring := GoKeyring{}
+	assert.Nil(t, keyring.Set(testTargetName, "alice", "secret"))
+	cred, err := GetGenericCredential(testTargetName + ":alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", cred.UserName)
+	assert.Equal(t, []byte("secret"), cred.CredentialBlob)
+
+	password, err := keyring.Get(testTargetName, "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", password)
+	assert.Nil(t, keyring.Set(testTargetName, "bob", "other"))
+
+	assert.Nil(t, keyring.Delete(testTargetName, "alice"))
+	assert.Equal(t, ErrKeyringNotFound, keyring.Delete(testTargetName, "alice"))
+	_, err = keyring.Get(testTargetName, "alice")
+	assert.Equal(t, ErrKeyringNotFound, err)
+
+	assert.Nil(t, keyring.DeleteAll(testTargetName))
+	assert.Equal(t, ErrKeyringNotFound, keyring.DeleteAll(testTargetName))
+}
+
+func TestKeyring_EndToEnd(t *testing.T) {
+	keyring := NewKeyring(KeyringConfig{ServiceName: testTargetName})
+	err := keyring.Set(KeyringItem{Key: "token", Data: []byte("secret"), Label: "label", Description: "description"})
+	assert.Nil(t, err)
+
+	item, err := keyring.Get("token")
+	assert.Nil(t, err)
+	assert.Equal(t, KeyringItem{Key: "token", Data: []byte("secret"), Label: "label", Description: "description"}, item)
+	metadata, err := keyring.GetMetadata("token")
+	assert.Nil(t, err)
+	assert.Nil(t, metadata.Data)
+	assert.False(t, metadata.ModificationTime.IsZero())
+
+	keys, err := keyring.Keys()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"token"}, keys)
+
+	assert.Nil(t, keyring.Remove("token"))
+	assert.Equal(t, ErrKeyNotFound, keyring.Remove("token"))
+	_, err = keyring.Get("token")
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestNamespace_EndToEnd(t *testing.T) {
+	ns := NewNamespace(testTargetName, "app")
+	for _, path := range [][]string{{"prod", "db"}, {"prod", "cache*"}, {"dev", "db"}} {
+		cred := NewGenericCredential(ns.TargetName(path...))
+		cred.CredentialBlob = []byte("secret")
+		assert.Nil(t, cred.Write())
+	}
+	defer NewNamespace(testTargetName).DeleteAll()
+
+	entries, err := ns.Children()
+	assert.Nil(t, err)
+	assert.Equal(t, []NamespaceEntry{{Name: "dev", Branch: true}, {Name: "prod", Branch: true}}, entries)
+
+	count, err := ns.Move([]string{"prod"}, []string{"staging"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	_, err = ns.Copy([]string{"dev"}, []string{"staging"})
+	assert.Nil(t, err)
+	_, err = ns.Copy([]string{"dev"}, []string{"staging"})
+	assert.True(t, errors.Is(err, ErrTargetExists))
+
+	var walked [][]string
+	err = ns.Walk([]string{"staging"}, func(path []string, cred *GenericCredential) error {
+		walked = append(walked, path)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"staging", "cache*"}, {"staging", "db"}}, walked)
+
+	count, err = ns.DeleteAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestPromote_EndToEnd(t *testing.T) {
+	dev := Environment{Name: "dev", Prefix: testTargetName + "/dev/"}
+	prod := Environment{Name: "prod", Prefix: testTargetName + "/prod/"}
+	cred := NewGenericCredential(dev.Prefix + "db")
+	cred.CredentialBlob = []byte("secret")
+	assert.Nil(t, cred.Write())
+	defer NewNamespace(testTargetName).DeleteAll()
+
+	diffs, err := CompareEnvironments(dev, prod)
+	assert.Nil(t, err)
+	assert.Equal(t, []EnvironmentDiff{{Key: "db", Type: TypeGeneric, Status: DiffMissing}}, diffs)
+
+	report := Promote(dev, prod, []string{"db", "missing"}, ImportOptions{})
+	assert.Equal(t, 1, report.Count(ImportCreated))
+	assert.Equal(t, 1, report.Count(ImportFailed))
+
+	diffs, err = CompareEnvironments(dev, prod)
+	assert.Nil(t, err)
+	assert.Equal(t, []EnvironmentDiff{{Key: "db", Type: TypeGeneric, Status: DiffEqual}}, diffs)
+}
+
+func TestApplyManifest_EndToEnd(t *testing.T) {
+	prefix := testTargetName + "/manifest/"
+	stale := NewGenericCredential(prefix + "stale")
+	assert.Nil(t, stale.Write())
+	defer NewNamespace(testTargetName).DeleteAll()
+
+	m := &Manifest{Prefix: prefix, Credentials: []ManifestCredential{
+		{TargetName: prefix + "db", UserName: "admin", Secret: SecretSource{Generate: &SecretGenerator{}}},
+	}}
+	plan, err := PlanManifest(m, ManifestOptions{Prune: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, plan.Count(PlanCreate))
+	assert.Equal(t, 1, plan.Count(PlanDelete))
+
+	_, err = ApplyManifest(m, ManifestOptions{Prune: true})
+	assert.Nil(t, err)
+	cred, err := GetGenericCredential(prefix + "db")
+	assert.Nil(t, err)
+	assert.Len(t, cred.CredentialBlob, DefaultGeneratedLength)
+	_, err = GetGenericCredential(prefix + "stale")
+	assert.True(t, errors.Is(err, ErrElementNotFound))
+
+	plan, err = PlanManifest(m, ManifestOptions{Prune: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []PlanChange{{TargetName: prefix + "db", Type: TypeGeneric, Action: PlanNoop}}, plan.Changes)
+}
+
+func TestCheckDrift_EndToEnd(t *testing.T) {
+	cred := NewGenericCredential(testTargetName + "/drift")
+	cred.Persist = PersistSession
+	assert.Nil(t, cred.Write())
+	defer cred.Delete()
+
+	report, err := CheckDrift(&Inventory{Rules: []InventoryRule{
+		{Pattern: testTargetName + "/*", Persist: []CredentialPersistence{PersistLocalMachine}},
+		{Pattern: "*", Severity: SeverityInfo},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, []DriftFinding{{
+		Kind:       FindingPersistence,
+		Severity:   SeverityWarning,
+		TargetName: testTargetName + "/drift",
+		Type:       TypeGeneric,
+		Rule:       testTargetName + "/*",
+		Message:    "persistence Session is not allowed",
+	}}, report.Findings)
+}